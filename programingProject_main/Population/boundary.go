@@ -0,0 +1,99 @@
+package main
+
+import "math"
+
+// The world is a square of the configured width. What happens at its edges
+// is set with Movement.Boundary:
+//
+//	torus    a family leaving on one side comes back on the other, and
+//	         distances are measured the short way round across the edges
+//	reflect  the edges are walls that families bounce off
+//	absorb   a family crossing an edge leaves the world for good (it migrates out)
+//
+// An empty boundary, as in configs written before the setting existed, is a torus.
+
+const (
+	BoundaryTorus   = "torus"
+	BoundaryReflect = "reflect"
+	BoundaryAbsorb  = "absorb"
+)
+
+// boundary returns the boundary mode of the ecosystem.
+func (e *Ecosystem) boundary() string {
+	if b := e.settings().Movement.Boundary; b != "" {
+		return b
+	}
+	return BoundaryTorus
+}
+
+// ApplyBoundary returns where a family that moved to p with velocity v ends
+// up and its velocity there. On a torus the position is wrapped into the
+// world; against walls it is mirrored back in and the velocity turned away
+// from the wall. inWorld is false when an absorbing edge took the family.
+func ApplyBoundary(p, v OrderedPair, width float64, boundary string) (position, velocity OrderedPair, inWorld bool) {
+	switch boundary {
+	case BoundaryReflect:
+		p.x, v.x = reflectCoordinate(p.x, v.x, width)
+		p.y, v.y = reflectCoordinate(p.y, v.y, width)
+		return p, v, true
+	case BoundaryAbsorb:
+		return p, v, p.x >= 0 && p.x < width && p.y >= 0 && p.y < width
+	default:
+		return WrapPosition(p, width), v, true
+	}
+}
+
+// reflectCoordinate mirrors one coordinate that crossed a wall at 0 or width
+// back into the world and points its velocity away from that wall.
+func reflectCoordinate(x, v, width float64) (float64, float64) {
+	switch {
+	case x < 0:
+		return math.Min(-x, width), math.Abs(v)
+	case x > width:
+		return math.Max(2*width-x, 0), -math.Abs(v)
+	}
+	return x, v
+}
+
+// Offset returns the displacement from a to b. On a torus it is the shortest
+// one, which may cross the edges of the world; otherwise the straight one.
+func Offset(a, b OrderedPair, width float64, boundary string) OrderedPair {
+	d := SubOrdered(b, a)
+	if (boundary == BoundaryTorus || boundary == "") && width > 0 {
+		d.x -= width * math.Round(d.x/width)
+		d.y -= width * math.Round(d.y/width)
+	}
+	return d
+}
+
+// offset is Offset in the ecosystem's world.
+func (e *Ecosystem) offset(a, b OrderedPair) OrderedPair {
+	return Offset(a, b, e.width, e.boundary())
+}
+
+// distanceBetween is the distance from a to b in the ecosystem's world.
+func (e *Ecosystem) distanceBetween(a, b OrderedPair) float64 {
+	return NormOrdered(e.offset(a, b))
+}
+
+// distanceBetween is the distance from a to b in the world the config describes,
+// for the functions that get the config instead of the ecosystem.
+func (c *EcosystemConfig) distanceBetween(a, b OrderedPair) float64 {
+	return NormOrdered(Offset(a, b, c.Width, c.Movement.Boundary))
+}
+
+// torusImages returns p and, on a torus, the copies of p one world width
+// away that lie within margin of the world, so a shape drawn around p that
+// sticks out over an edge shows up again on the other side.
+func torusImages(p OrderedPair, width, margin float64) []OrderedPair {
+	images := []OrderedPair{p}
+	for _, dx := range []float64{-width, 0, width} {
+		for _, dy := range []float64{-width, 0, width} {
+			q := OrderedPair{x: p.x + dx, y: p.y + dy}
+			if (dx != 0 || dy != 0) && q.x > -margin && q.x < width+margin && q.y > -margin && q.y < width+margin {
+				images = append(images, q)
+			}
+		}
+	}
+	return images
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+)
+
+// A checkpoint is a JSON file holding the complete state of a run: the config,
+// every family and plant, the lake, the settlement, the weather counter, the step number and
+// the state of the random stream. Loading a checkpoint and continuing gives
+// exactly the same results as a run that was never stopped.
+
+// checkpointVersion is bumped whenever the checkpoint layout changes in a way
+// that older checkpoints would load wrongly. New fields whose zero value is a
+// correct default do not need a new version.
+const checkpointVersion = 6
+
+type checkpointFile struct {
+	Version              int                `json:"version"`
+	Step                 int                `json:"step"`
+	Config               EcosystemConfig    `json:"config"`
+	Width                float64            `json:"width"`
+	Weather              string             `json:"weather"`
+	WeatherChangeCounter int                `json:"weather_change_counter"`
+	Lake                 checkpointLake     `json:"lake"`
+	Settlement           *Settlement        `json:"settlement"` // null until the humans found one
+	CarryingCapacity     map[string]int     `json:"carrying_capacity"`
+	Families             []checkpointFamily `json:"families"`
+	Plants               []checkpointPlant  `json:"plants"`
+	RNG                  []byte             `json:"rng"`
+}
+
+type checkpointLake struct {
+	Radius    float64     `json:"radius"`
+	MaxRadius float64     `json:"max_radius"`
+	Position  OrderedPair `json:"position"`
+}
+
+type checkpointFamily struct {
+	Species             Species            `json:"species"`
+	Size                int                `json:"size"`
+	MovementSpeed       OrderedPair        `json:"movement_speed"`
+	Position            OrderedPair        `json:"position"`
+	MovementDirection   OrderedPair        `json:"movement_direction"`
+	Acceleration        OrderedPair        `json:"acceleration"`
+	PropulsionDirection OrderedPair        `json:"propulsion_direction"`
+	Energy              float64            `json:"energy"`
+	Hydration           float64            `json:"hydration"`
+	Stages              [numLifeStages]int `json:"stages"`
+	Hunger              float64            `json:"hunger"`
+}
+
+type checkpointPlant struct {
+	Position OrderedPair `json:"position"`
+	Size     float64     `json:"size"`
+	MaxSize  float64     `json:"max_size"`
+}
+
+// WriteCheckpoint encodes the complete state of the ecosystem to w.
+func WriteCheckpoint(w io.Writer, ecosystem *Ecosystem) error {
+	ecosystem.random() // make sure there is a random stream to save
+	rngState, err := ecosystem.rngSource.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	cp := checkpointFile{
+		Version:              checkpointVersion,
+		Step:                 ecosystem.step,
+		Config:               *ecosystem.settings(),
+		Width:                ecosystem.width,
+		Weather:              ecosystem.weather,
+		WeatherChangeCounter: ecosystem.weatherChangeCounter,
+		Lake: checkpointLake{
+			Radius:    ecosystem.Lake.Radius,
+			MaxRadius: ecosystem.Lake.MaxRadius,
+			Position:  ecosystem.Lake.Position,
+		},
+		Settlement:       ecosystem.settlement,
+		CarryingCapacity: ecosystem.CarryingCapacity,
+		Families:         make([]checkpointFamily, len(ecosystem.Families)),
+		Plants:           make([]checkpointPlant, len(ecosystem.Plants)),
+		RNG:              rngState,
+	}
+	for i, f := range ecosystem.Families {
+		cp.Families[i] = checkpointFamily{
+			Species:             f.species,
+			Size:                f.Size,
+			MovementSpeed:       f.MovementSpeed,
+			Position:            f.Position,
+			MovementDirection:   f.MovementDirection,
+			Acceleration:        f.Acceleration,
+			PropulsionDirection: f.PropulsionDirection,
+			Energy:              f.energy,
+			Hydration:           f.hydration,
+			Stages:              f.stages,
+			Hunger:              f.hunger,
+		}
+	}
+	for i, p := range ecosystem.Plants {
+		cp.Plants[i] = checkpointPlant{Position: p.position, Size: p.size, MaxSize: p.maxSize}
+	}
+
+	enc := json.NewEncoder(w)
+	return enc.Encode(cp)
+}
+
+// ReadCheckpoint decodes an ecosystem written by WriteCheckpoint.
+func ReadCheckpoint(r io.Reader) (Ecosystem, error) {
+	var cp checkpointFile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cp); err != nil {
+		return Ecosystem{}, fmt.Errorf("invalid checkpoint: %w", err)
+	}
+	if cp.Version != checkpointVersion {
+		return Ecosystem{}, fmt.Errorf("unsupported checkpoint version %d (this build reads version %d)", cp.Version, checkpointVersion)
+	}
+	if err := cp.Config.Validate(); err != nil {
+		return Ecosystem{}, fmt.Errorf("checkpoint config: %w", err)
+	}
+
+	src := &rand.PCG{}
+	if err := src.UnmarshalBinary(cp.RNG); err != nil {
+		return Ecosystem{}, fmt.Errorf("checkpoint random state: %w", err)
+	}
+
+	cfg := cp.Config
+	eco := Ecosystem{
+		Families: make([]Family, len(cp.Families)),
+		Plants:   make([]Plant, len(cp.Plants)),
+		width:    cp.Width,
+		weather:  cp.Weather,
+		Lake: Lake{
+			Radius:    cp.Lake.Radius,
+			MaxRadius: cp.Lake.MaxRadius,
+			Position:  cp.Lake.Position,
+		},
+		settlement:           cp.Settlement,
+		CarryingCapacity:     cp.CarryingCapacity,
+		weatherChangeCounter: cp.WeatherChangeCounter,
+		step:                 cp.Step,
+		config:               &cfg,
+		rng:                  rand.New(src),
+		rngSource:            src,
+	}
+	if eco.CarryingCapacity == nil {
+		eco.CarryingCapacity = make(map[string]int)
+	}
+	for i, f := range cp.Families {
+		eco.Families[i] = Family{
+			Size:                f.Size,
+			MovementSpeed:       f.MovementSpeed,
+			Position:            f.Position,
+			MovementDirection:   f.MovementDirection,
+			Acceleration:        f.Acceleration,
+			PropulsionDirection: f.PropulsionDirection,
+			species:             f.Species,
+			energy:              f.Energy,
+			hydration:           f.Hydration,
+			stages:              f.Stages,
+			hunger:              f.Hunger,
+		}
+	}
+	for i, p := range cp.Plants {
+		eco.Plants[i] = Plant{position: p.Position, size: p.Size, maxSize: p.MaxSize}
+	}
+	return eco, nil
+}
+
+// SaveCheckpoint writes the checkpoint to path. The file is replaced
+// atomically, so an interrupted save never leaves a half-written checkpoint.
+func SaveCheckpoint(path string, ecosystem *Ecosystem) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if err := WriteCheckpoint(tmp, ecosystem); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadCheckpoint reads a checkpoint written by SaveCheckpoint.
+func LoadCheckpoint(path string) (Ecosystem, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Ecosystem{}, err
+	}
+	defer file.Close()
+	eco, err := ReadCheckpoint(file)
+	if err != nil {
+		return Ecosystem{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return eco, nil
+}
+
+// CheckpointObserver saves the run to a checkpoint file every n steps and
+// once more when the run finishes.
+type CheckpointObserver struct {
+	path      string
+	every     int
+	ecosystem *Ecosystem
+}
+
+// NewCheckpointObserver saves every `every` steps; 0 only saves at the end of the run.
+func NewCheckpointObserver(path string, every int) *CheckpointObserver {
+	return &CheckpointObserver{path: path, every: every}
+}
+
+func (o *CheckpointObserver) Observe(step int, ecosystem *Ecosystem) error {
+	o.ecosystem = ecosystem
+	if o.every > 0 && step > 0 && step%o.every == 0 {
+		return SaveCheckpoint(o.path, ecosystem)
+	}
+	return nil
+}
+
+func (o *CheckpointObserver) Finish() error {
+	if o.ecosystem == nil {
+		return nil
+	}
+	return SaveCheckpoint(o.path, o.ecosystem)
+}
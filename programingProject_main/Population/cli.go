@@ -0,0 +1,445 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"programingProject_main/gifhelper"
+	"strconv"
+	"strings"
+)
+
+// Exit codes returned by runCLI.
+const (
+	exitOK    = 0
+	exitError = 1 // the command ran but failed (bad scenario, I/O error, ...)
+	exitUsage = 2 // the command line itself was wrong
+)
+
+const cliUsage = `Usage: Population <command> [flags]
+
+Commands:
+  run       simulate and write the population log (CSV)
+  render    simulate and draw the run as an animated GIF
+  sweep     run the scenario and its example variants over several seeds
+  validate  check one or more scenario files
+  resume    continue a run from a checkpoint file
+
+Run "Population <command> --help" for the flags of a command.
+`
+
+// usageError marks errors caused by bad command-line input.
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...interface{}) error {
+	return usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// runCLI executes the command line and returns the process exit code.
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, cliUsage)
+		return exitUsage
+	}
+
+	var err error
+	switch args[0] {
+	case "run":
+		err = runCommand(args[1:], stdout, stderr)
+	case "render":
+		err = renderCommand(args[1:], stdout, stderr)
+	case "sweep":
+		err = sweepCommand(args[1:], stdout, stderr)
+	case "validate":
+		err = validateCommand(args[1:], stdout, stderr)
+	case "resume":
+		err = resumeCommand(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, cliUsage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], cliUsage)
+		return exitUsage
+	}
+
+	var usageErr usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitUsage
+	default:
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return exitError
+	}
+}
+
+// simulationFlags are the flags shared by every command that runs a simulation.
+type simulationFlags struct {
+	config   string
+	steps    int
+	timeStep float64
+	seed     int64
+	workers  int
+	terrain  string
+	boundary string
+}
+
+func addSimulationFlags(fs *flag.FlagSet, sf *simulationFlags) {
+	fs.StringVar(&sf.config, "config", "", "scenario file (JSON or YAML); the built-in defaults are used when empty")
+	fs.IntVar(&sf.steps, "steps", 1000, "number of simulation steps")
+	fs.Float64Var(&sf.timeStep, "dt", 0, "time step of each simulation step (0 uses the scenario's movement.time_step)")
+	fs.Int64Var(&sf.seed, "seed", 0, "random seed; runs with the same seed produce identical output (0 uses the scenario's seed, or picks one)")
+	fs.IntVar(&sf.workers, "workers", 0, "goroutines used per step; output is identical for the same seed and worker count (0 uses the scenario's workers)")
+	fs.StringVar(&sf.terrain, "terrain", "", "terrain map (PNG or CSV) that replaces the scenario's map")
+	fs.StringVar(&sf.boundary, "boundary", "", "world edges: torus, reflect or absorb (empty uses the scenario's movement.boundary)")
+}
+
+// parseFlags parses the command's flags and rejects stray positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{msg: err.Error()}
+	}
+	if fs.NArg() > 0 {
+		return usagef("%s: unexpected argument %q", fs.Name(), fs.Arg(0))
+	}
+	return nil
+}
+
+func newFlagSet(name, summary string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: Population %s [flags]\n\n%s\n\nFlags:\n", name, summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// load validates the shared flags and loads the scenario they point to.
+func (sf simulationFlags) load() (EcosystemConfig, error) {
+	if sf.steps < 1 {
+		return EcosystemConfig{}, usagef("--steps must be at least 1, got %d", sf.steps)
+	}
+	if sf.timeStep < 0 {
+		return EcosystemConfig{}, usagef("--dt must not be negative, got %g", sf.timeStep)
+	}
+	if sf.workers < 0 {
+		return EcosystemConfig{}, usagef("--workers must not be negative, got %d", sf.workers)
+	}
+	if sf.boundary != "" && !containsString(validBoundaries, sf.boundary) {
+		return EcosystemConfig{}, usagef("--boundary must be one of %s, got %q", strings.Join(validBoundaries, ", "), sf.boundary)
+	}
+	cfg := NewDefaultEcosystemConfig()
+	if sf.config != "" {
+		loaded, err := LoadScenario(sf.config)
+		if err != nil {
+			return EcosystemConfig{}, err
+		}
+		cfg = loaded
+	}
+	if sf.seed != 0 {
+		cfg.Seed = sf.seed
+	}
+	if sf.timeStep > 0 {
+		cfg.Movement.TimeStep = sf.timeStep
+	}
+	if sf.workers > 0 {
+		cfg.Workers = sf.workers
+	}
+	if sf.terrain != "" {
+		terrain, err := LoadTerrainMap(sf.terrain)
+		if err != nil {
+			return EcosystemConfig{}, err
+		}
+		cfg.Terrain.Map = terrain
+	}
+	if sf.boundary != "" {
+		cfg.Movement.Boundary = sf.boundary
+	}
+	// The scenario was valid on its own; the flags must not break it.
+	if err := cfg.Validate(); err != nil {
+		return EcosystemConfig{}, usageError{msg: err.Error()}
+	}
+	return cfg, nil
+}
+
+func runCommand(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("run", "Simulate the ecosystem and write the population log read by app.R.", stderr)
+	var sf simulationFlags
+	addSimulationFlags(fs, &sf)
+	out := fs.String("out", "population_log.csv", "path of the population log")
+	logEvery := fs.Int("log-every", 1, "write a log row every N steps")
+	quiet := fs.Bool("quiet", false, "do not print the populations to the console")
+	var cf checkpointFlags
+	addCheckpointFlags(fs, &cf, "")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *logEvery < 1 {
+		return usagef("--log-every must be at least 1, got %d", *logEvery)
+	}
+	if err := cf.check(); err != nil {
+		return err
+	}
+	cfg, err := sf.load()
+	if err != nil {
+		return err
+	}
+
+	logFile, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	eco := BuildEcosystemFromConfig(cfg)
+	fmt.Fprintf(stdout, "seed=%d\n", eco.settings().Seed)
+	observers := []StepObserver{NewCSVObserver(logFile, *logEvery)}
+	if !*quiet {
+		observers = append(observers, NewConsoleObserver(stdout, *logEvery))
+	}
+	if cf.path != "" {
+		observers = append(observers, NewCheckpointObserver(cf.path, cf.every))
+	}
+	if err := RunSimulation(&eco, sf.steps, cfg.Movement.TimeStep, observers...); err != nil {
+		return err
+	}
+	if err := logFile.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Population data saved to %s\n", *out)
+	if cf.path != "" {
+		fmt.Fprintf(stdout, "Checkpoint saved to %s\n", cf.path)
+	}
+	return nil
+}
+
+// checkpointFlags are the flags of the commands that can save checkpoints.
+type checkpointFlags struct {
+	path  string
+	every int
+}
+
+func addCheckpointFlags(fs *flag.FlagSet, cf *checkpointFlags, defaultPath string) {
+	fs.StringVar(&cf.path, "checkpoint", defaultPath, "checkpoint file the run state is saved to")
+	fs.IntVar(&cf.every, "checkpoint-every", 0, "also save the checkpoint every N steps (0 only saves at the end)")
+}
+
+func (cf checkpointFlags) check() error {
+	if cf.every < 0 {
+		return usagef("--checkpoint-every must not be negative, got %d", cf.every)
+	}
+	if cf.every > 0 && cf.path == "" {
+		return usagef("--checkpoint-every needs --checkpoint")
+	}
+	return nil
+}
+
+func resumeCommand(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("resume", "Continue a run from a checkpoint as if it had never stopped. The population\nlog is appended to and the checkpoint is saved again when the run ends.", stderr)
+	steps := fs.Int("steps", 1000, "number of additional simulation steps")
+	out := fs.String("out", "population_log.csv", "population log to append to (created when missing)")
+	logEvery := fs.Int("log-every", 1, "write a log row every N steps; use the value of the original run")
+	quiet := fs.Bool("quiet", false, "do not print the populations to the console")
+	var cf checkpointFlags
+	addCheckpointFlags(fs, &cf, "checkpoint.json")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *steps < 1 {
+		return usagef("--steps must be at least 1, got %d", *steps)
+	}
+	if *logEvery < 1 {
+		return usagef("--log-every must be at least 1, got %d", *logEvery)
+	}
+	if cf.path == "" {
+		return usagef("--checkpoint must not be empty")
+	}
+	if err := cf.check(); err != nil {
+		return err
+	}
+
+	eco, err := LoadCheckpoint(cf.path)
+	if err != nil {
+		return err
+	}
+
+	_, statErr := os.Stat(*out)
+	appending := statErr == nil
+	logFile, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	csvObserver := NewCSVObserver(logFile, *logEvery)
+	if appending {
+		csvObserver.WithoutHeader()
+	}
+
+	fmt.Fprintf(stdout, "resuming at step=%d seed=%d\n", eco.step, eco.settings().Seed)
+	observers := []StepObserver{csvObserver, NewCheckpointObserver(cf.path, cf.every)}
+	if !*quiet {
+		observers = append(observers, NewConsoleObserver(stdout, *logEvery))
+	}
+	if err := RunSimulation(&eco, *steps, eco.settings().Movement.TimeStep, observers...); err != nil {
+		return err
+	}
+	if err := logFile.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Population data saved to %s\n", *out)
+	fmt.Fprintf(stdout, "Checkpoint saved to %s\n", cf.path)
+	return nil
+}
+
+func renderCommand(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("render", "Simulate the ecosystem and draw it as an animated GIF.", stderr)
+	var sf simulationFlags
+	addSimulationFlags(fs, &sf)
+	out := fs.String("out", "Animal_Sim.gif", "GIF file name (\".out.gif\" is appended)")
+	canvasWidth := fs.Int("canvas", 500, "width and height of each frame in pixels")
+	frameEvery := fs.Int("frame-every", 10, "draw a frame every N steps")
+	csvPath := fs.String("csv", "", "also write the population log to this path")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *canvasWidth < 1 {
+		return usagef("--canvas must be at least 1, got %d", *canvasWidth)
+	}
+	if *frameEvery < 1 {
+		return usagef("--frame-every must be at least 1, got %d", *frameEvery)
+	}
+	cfg, err := sf.load()
+	if err != nil {
+		return err
+	}
+
+	config := Config{
+		CanvasWidth:     *canvasWidth,
+		AgentColor:      Color{R: 255, G: 255, B: 255, A: 255}, // Generic color
+		BackgroundColor: Color{R: 173, G: 216, B: 230},         // Light blue background
+	}
+	frames := NewFrameObserver(config, *frameEvery)
+	observers := []StepObserver{frames}
+	if *csvPath != "" {
+		logFile, err := os.Create(*csvPath)
+		if err != nil {
+			return err
+		}
+		defer logFile.Close()
+		observers = append(observers, NewCSVObserver(logFile, 1))
+	}
+
+	eco := BuildEcosystemFromConfig(cfg)
+	fmt.Fprintf(stdout, "seed=%d\n", eco.settings().Seed)
+	if err := RunSimulation(&eco, sf.steps, cfg.Movement.TimeStep, observers...); err != nil {
+		return err
+	}
+	if *csvPath != "" {
+		fmt.Fprintf(stdout, "Population data saved to %s\n", *csvPath)
+	}
+
+	gifhelper.ImagesToGIF(frames.Frames(), *out)
+	fmt.Fprintf(stdout, "GIF drawn to %s.out.gif\n", *out)
+	return nil
+}
+
+func sweepCommand(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("sweep", "Run the scenario and the variants from ExampleConfigVariants over several seeds\nand write the final populations of every run.", stderr)
+	var sf simulationFlags
+	addSimulationFlags(fs, &sf)
+	seeds := fs.Int("seeds", 5, "number of seeds per variant, counting up from --seed (or 1)")
+	out := fs.String("out", "sweep.csv", "path of the sweep results")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *seeds < 1 {
+		return usagef("--seeds must be at least 1, got %d", *seeds)
+	}
+	base, err := sf.load()
+	if err != nil {
+		return err
+	}
+	firstSeed := base.Seed
+	if firstSeed == 0 {
+		firstSeed = 1
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	csvWriter := csv.NewWriter(file)
+
+	header := append([]string{"variant", "seed"}, PopulationLogHeader(&base)[1:]...)
+	if err := csvWriter.Write(header); err != nil {
+		return err
+	}
+
+	variants := append([]EcosystemConfig{base}, ExampleConfigVariants(base)...)
+	for v, variant := range variants {
+		for s := 0; s < *seeds; s++ {
+			cfg := variant.Clone()
+			cfg.Seed = firstSeed + int64(s)
+			eco := BuildEcosystemFromConfig(cfg)
+			if err := RunSimulation(&eco, sf.steps, cfg.Movement.TimeStep); err != nil {
+				return err
+			}
+			row := PopulationLogRow(sf.steps, &eco)
+			if err := csvWriter.Write(append([]string{strconv.Itoa(v), strconv.FormatInt(cfg.Seed, 10)}, row[1:]...)); err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "variant=%d seed=%d %s\n", v, cfg.Seed, FormatPopulationLine(sf.steps, &eco))
+		}
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Sweep results saved to %s\n", *out)
+	return nil
+}
+
+func validateCommand(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, "Usage: Population validate <scenario file>...\n\nCheck scenario files and report every problem found.\n")
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{msg: err.Error()}
+	}
+	if fs.NArg() == 0 {
+		return usagef("validate: no scenario file given")
+	}
+
+	failed := 0
+	for _, path := range fs.Args() {
+		if _, err := LoadScenario(path); err != nil {
+			fmt.Fprintln(stderr, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: ok\n", path)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d scenario files are invalid", failed, fs.NArg())
+	}
+	return nil
+}
@@ -1,56 +1,215 @@
 package main
 
 type MovementConfig struct {
-	MaxSpeed         float64
-	TimeStep         float64
-	SeparationWeight float64
+	MaxSpeed            float64 `json:"max_speed"`
+	TimeStep            float64 `json:"time_step"`
+	SeparationWeight    float64 `json:"separation_weight"`
+	SeparationThreshold float64 `json:"separation_threshold"`
+	InitialSpeed        float64 `json:"initial_speed"`
+	PursuitWeight       float64 `json:"pursuit_weight"` // pull of predators toward the nearest prey they perceive
+	EvasionWeight       float64 `json:"evasion_weight"` // push of prey away from the predators they perceive
+	Boundary            string  `json:"boundary"`       // torus, reflect or absorb (see boundary.go)
 }
 
 type PopulationConfig struct {
-	CarryingCapacities map[string]int
-	InitialPopulations map[string]int
-	MinFamilySize      int
-	MaxFamilySize      int
+	CarryingCapacities map[string]int `json:"carrying_capacities"`
+	InitialPopulations map[string]int `json:"initial_populations"`
+	FamiliesPerSpecies int            `json:"families_per_species"`
+	MinFamilySize      int            `json:"min_family_size"`
+	MaxFamilySize      int            `json:"max_family_size"`
+	MergingThreshold   float64        `json:"merging_threshold"`
+	EatingThreshold    float64        `json:"eating_threshold"`
 }
 
 type WeatherConfig struct {
-	Enabled        bool
-	StepInterval   int
-	InitialWeather string
+	Enabled        bool   `json:"enabled"`
+	StepInterval   int    `json:"step_interval"`
+	InitialWeather string `json:"initial_weather"`
 }
 
 type LakeConfig struct {
-	Radius    float64
-	MaxRadius float64
-	Center    OrderedPair
+	Radius    float64     `json:"radius"`
+	MaxRadius float64     `json:"max_radius"`
+	Center    OrderedPair `json:"center"`
+}
+
+// WaterConfig describes a body of water besides the lake (see water.go). The
+// shape decides which of the other fields are used.
+type WaterConfig struct {
+	Shape  string        `json:"shape"`  // circle, polygon or river
+	Center OrderedPair   `json:"center"` // centre of a circle
+	Radius float64       `json:"radius"` // radius of a circle
+	Points []OrderedPair `json:"points"` // corners of a polygon, or the course of a river
+	Width  float64       `json:"width"`  // width of a river
+}
+
+// PlantConfig holds the parameters that used to be the plant constants in datatypes.go.
+type PlantConfig struct {
+	Count             int     `json:"count"`
+	MinInitialSize    float64 `json:"min_initial_size"`
+	MaxInitialSize    float64 `json:"max_initial_size"`
+	GrowthCoefficient float64 `json:"growth_coefficient"`
+	ConsumptionRate   float64 `json:"consumption_rate"`
+	ConversionFactor  float64 `json:"conversion_factor"` // growth rate gained per unit of plant mass eaten
+	MaxSize           float64 `json:"max_size"`          // biomass a plant grows toward in Sunny weather
+	SeedSize          float64 `json:"seed_size"`         // biomass a dead plant regrows with
+	RegrowthChance    float64 `json:"regrowth_chance"`   // chance per step that a dead plant regrows
+}
+
+// VegetationConfig controls how plants spread, die from frost and profit from the lake.
+type VegetationConfig struct {
+	SeedingChance   float64 `json:"seeding_chance"`    // chance per step that a plant at half its maximum or more drops a seed
+	SeedRadius      float64 `json:"seed_radius"`       // how far from its parent a seed can land
+	MinSpacing      float64 `json:"min_spacing"`       // a seed landing closer than this to another plant does not start a new plant
+	MaxPlants       int     `json:"max_plants"`        // plants stop spreading at this count
+	FrostKillChance float64 `json:"frost_kill_chance"` // chance per step that frost kills a plant in Frozen weather
+	LakeGrowthBonus float64 `json:"lake_growth_bonus"` // extra growth rate at the shore, 1 doubles it
+	LakeInfluence   float64 `json:"lake_influence"`    // distance from the shore where the bonus fades out
+}
+
+// ForagingConfig controls how prey look for food. Hunger goes from 0 (fed) to 1
+// (starving). With an energy reserve it follows the reserve (see energy.go);
+// otherwise it rises over time and falls with every unit of plant mass eaten.
+type ForagingConfig struct {
+	Radius       float64 `json:"radius"`        // how far away prey sense plant mass
+	Weight       float64 `json:"weight"`        // pull toward plant mass of a fed family
+	HungerWeight float64 `json:"hunger_weight"` // extra pull of a starving family
+	HungerRate   float64 `json:"hunger_rate"`   // hunger gained per unit of time
+	Satiety      float64 `json:"satiety"`       // hunger lost per unit of plant mass eaten
+}
+
+// DynamicsConfig selects the population model (see dynamics.go) and holds
+// the parameters of predation (see predation.go). Attack rates are set per
+// pair of species in EcosystemConfig.Interactions.
+type DynamicsConfig struct {
+	Model                string  `json:"model"`                 // default, discrete, lotka_volterra, holling_ii, holling_iii or beverton_holt
+	HandlingTime         float64 `json:"handling_time"`         // time a predator spends on each catch (Holling type II and III)
+	ConversionEfficiency float64 `json:"conversion_efficiency"` // predators born per prey caught
+	PreyPlantBonus       float64 `json:"prey_plant_bonus"`      // flat growth rate prey get from plants (discrete model)
+}
+
+// TerrainEffect is how one terrain type changes what happens on it. Every
+// factor is 1 on grassland by default.
+type TerrainEffect struct {
+	Speed     float64 `json:"speed"`     // multiplies the maximum speed of families on it
+	Growth    float64 `json:"growth"`    // multiplies the growth rate of plants on it
+	Detection float64 `json:"detection"` // multiplies the distance from which predators see prey on it
+}
+
+// TerrainConfig holds the terrain map and the effect of each terrain type (see terrain.go).
+type TerrainConfig struct {
+	File      string        `json:"file"` // PNG or CSV file the map is loaded from, relative to the scenario file
+	Map       []string      `json:"map"`  // one string per row, one letter per cell (g, f, r or m); empty means all grassland
+	Grassland TerrainEffect `json:"grassland"`
+	Forest    TerrainEffect `json:"forest"`
+	Rock      TerrainEffect `json:"rock"`
+	Marsh     TerrainEffect `json:"marsh"`
+}
+
+// HumanConfig controls the settlement of the humans and their farming (see
+// humans.go). Which species humans hunt is set in EcosystemConfig.Interactions.
+type HumanConfig struct {
+	SettlementRadius    float64 `json:"settlement_radius"`    // other species keep out of this distance from the settlement; 0 means humans found no settlement
+	SettlementAvoidance float64 `json:"settlement_avoidance"` // push of other species away from the settlement
+	FarmRadius          float64 `json:"farm_radius"`          // humans plant and harvest within this distance from the settlement
+	HomeWeight          float64 `json:"home_weight"`          // pull of humans back to the settlement once they leave the farmland
+	PlantingChance      float64 `json:"planting_chance"`      // chance per step that a human family on the farmland plants a crop
+	CropSize            float64 `json:"crop_size"`            // biomass a crop grows toward in Sunny weather; 0 uses plants.max_size
+	HarvestRate         float64 `json:"harvest_rate"`         // plant mass each human harvests per step
+}
+
+// EnergyConfig controls the energy reserve of the families. Energies are per
+// individual and rates are per unit of time.
+type EnergyConfig struct {
+	Enabled               bool    `json:"enabled"`                // false falls back to the fixed growth rates of the species
+	Max                   float64 `json:"max"`                    // largest reserve an individual can hold
+	Initial               float64 `json:"initial"`                // reserve of new families
+	BaseCost              float64 `json:"base_cost"`              // energy burnt standing still
+	SpeedCost             float64 `json:"speed_cost"`             // extra energy burnt at the maximum speed
+	SizeCost              float64 `json:"size_cost"`              // extra energy burnt in a family of the maximum size
+	PlantEnergy           float64 `json:"plant_energy"`           // energy per unit of plant mass eaten (prey)
+	KillEnergy            float64 `json:"kill_energy"`            // energy per prey caught (predators), at most max
+	StarvationThreshold   float64 `json:"starvation_threshold"`   // below this reserve individuals start dying
+	StarvationRate        float64 `json:"starvation_rate"`        // death rate with an empty reserve
+	ReproductionThreshold float64 `json:"reproduction_threshold"` // above this reserve the family has young
+	ReproductionRate      float64 `json:"reproduction_rate"`      // birth rate with a full reserve
+	ReproductionCost      float64 `json:"reproduction_cost"`      // energy the family spends on each newborn
+}
+
+// HydrationConfig controls the hydration reserve of the families (see
+// hydration.go). Hydration is per individual and rates are per unit of time.
+type HydrationConfig struct {
+	Enabled              bool    `json:"enabled"`               // false means families never get thirsty
+	Max                  float64 `json:"max"`                   // largest reserve an individual can hold
+	Initial              float64 `json:"initial"`               // reserve of new families
+	LossRate             float64 `json:"loss_rate"`             // water lost, changed by the weather (see CoefficientOfThirstIncrease)
+	DrinkDistance        float64 `json:"drink_distance"`        // families this close to a shore drink
+	DrinkRate            float64 `json:"drink_rate"`            // water regained while drinking
+	DehydrationThreshold float64 `json:"dehydration_threshold"` // below this reserve individuals start dying
+	DehydrationRate      float64 `json:"dehydration_rate"`      // death rate with an empty reserve
+	ThirstWeight         float64 `json:"thirst_weight"`         // pull toward the nearest shore of a parched family
 }
 
 type EcosystemConfig struct {
-	Width      float64
-	Movement   MovementConfig
-	Population PopulationConfig
-	Weather    WeatherConfig
-	Lake       LakeConfig
+	Seed         int64              `json:"seed"`    // 0 picks a seed from the clock
+	Workers      int                `json:"workers"` // goroutines per step; results depend on seed and worker count
+	Width        float64            `json:"width"`
+	Species      map[string]Species `json:"species"`
+	Interactions InteractionMatrix  `json:"interactions"` // which species eat, compete with and avoid which (see interactions.go)
+	Movement     MovementConfig     `json:"movement"`
+	Population   PopulationConfig   `json:"population"`
+	Dynamics     DynamicsConfig     `json:"dynamics"`
+	Weather      WeatherConfig      `json:"weather"`
+	Lake         LakeConfig         `json:"lake"`
+	Waters       []WaterConfig      `json:"waters"` // ponds, lakes and rivers besides the lake
+	Plants       PlantConfig        `json:"plants"`
+	Vegetation   VegetationConfig   `json:"vegetation"`
+	Foraging     ForagingConfig     `json:"foraging"`
+	Energy       EnergyConfig       `json:"energy"`
+	Hydration    HydrationConfig    `json:"hydration"`
+	Humans       HumanConfig        `json:"humans"`
+	Terrain      TerrainConfig      `json:"terrain"`
 }
 
 func NewDefaultMovementConfig() MovementConfig {
 	return MovementConfig{
-		MaxSpeed:         Max_Family_Speed,
-		TimeStep:         1.0,
-		SeparationWeight: 2.0,
+		MaxSpeed:            Max_Family_Speed,
+		TimeStep:            0.1,
+		SeparationWeight:    2.0,
+		SeparationThreshold: Separation_Threshold,
+		InitialSpeed:        10.0,
+		PursuitWeight:       4.0,
+		EvasionWeight:       3.0,
+		Boundary:            BoundaryTorus,
 	}
 }
 
 func NewDefaultPopulationConfig() PopulationConfig {
 	cc := make(map[string]int)
+	for k, v := range defaultCarryingCapacity {
+		cc[k] = v
+	}
+	ip := make(map[string]int)
 	for k, v := range initialPopulations {
-		cc[k] = int(float64(v) * 1.5)
+		ip[k] = v
 	}
 	return PopulationConfig{
 		CarryingCapacities: cc,
-		InitialPopulations: initialPopulations,
+		InitialPopulations: ip,
+		FamiliesPerSpecies: initial_family_number,
 		MinFamilySize:      Smallest_Family_Size,
 		MaxFamilySize:      Max_Family_Size,
+		MergingThreshold:   Merging_Threshold,
+		EatingThreshold:    Eating_Threshold,
+	}
+}
+
+func NewDefaultDynamicsConfig() DynamicsConfig {
+	return DynamicsConfig{
+		Model:                "default",
+		HandlingTime:         0.5,
+		ConversionEfficiency: 0.2,
+		PreyPlantBonus:       PreyPlantCoefficient,
 	}
 }
 
@@ -72,13 +231,124 @@ func NewDefaultLakeConfig() LakeConfig {
 	}
 }
 
+func NewDefaultPlantConfig() PlantConfig {
+	return PlantConfig{
+		Count:             200,
+		MinInitialSize:    5,
+		MaxInitialSize:    15,
+		GrowthCoefficient: PlantCoefficient,
+		ConsumptionRate:   consumptionRate,
+		ConversionFactor:  PlantGrowthConversionFactor,
+		MaxSize:           30,
+		SeedSize:          0.5,
+		RegrowthChance:    0.02,
+	}
+}
+
+func NewDefaultVegetationConfig() VegetationConfig {
+	return VegetationConfig{
+		SeedingChance:   0.01,
+		SeedRadius:      15,
+		MinSpacing:      3,
+		MaxPlants:       2000,
+		FrostKillChance: 0.02,
+		LakeGrowthBonus: 1.0,
+		LakeInfluence:   60,
+	}
+}
+
+func NewDefaultForagingConfig() ForagingConfig {
+	return ForagingConfig{
+		Radius:       40,
+		Weight:       1.0,
+		HungerWeight: 4.0,
+		HungerRate:   0.5,
+		Satiety:      1.0,
+	}
+}
+
+func NewDefaultEnergyConfig() EnergyConfig {
+	// Standing still, an individual burns its way from the initial reserve down
+	// to starvation in 80 units of time, at full speed in a family of the
+	// maximum size in about 27. One prey caught fills a predator's reserve.
+	return EnergyConfig{
+		Enabled:               true,
+		Max:                   10,
+		Initial:               6,
+		BaseCost:              0.05,
+		SpeedCost:             0.05,
+		SizeCost:              0.05,
+		PlantEnergy:           5,
+		KillEnergy:            10,
+		StarvationThreshold:   2,
+		StarvationRate:        0.5,
+		ReproductionThreshold: 7,
+		ReproductionRate:      0.3,
+		ReproductionCost:      2,
+	}
+}
+
+func NewDefaultHydrationConfig() HydrationConfig {
+	return HydrationConfig{
+		Enabled:              true,
+		Max:                  10,
+		Initial:              8,
+		LossRate:             0.1,
+		DrinkDistance:        10,
+		DrinkRate:            5,
+		DehydrationThreshold: 3,
+		DehydrationRate:      0.5,
+		ThirstWeight:         12,
+	}
+}
+
+func NewDefaultHumanConfig() HumanConfig {
+	return HumanConfig{
+		SettlementRadius:    30,
+		SettlementAvoidance: 3.0,
+		FarmRadius:          80,
+		HomeWeight:          6.0,
+		PlantingChance:      0.2,
+		CropSize:            40,
+		HarvestRate:         0.1,
+	}
+}
+
+func NewDefaultTerrainConfig() TerrainConfig {
+	return TerrainConfig{
+		Grassland: TerrainEffect{Speed: 1, Growth: 1, Detection: 1},
+		Forest:    TerrainEffect{Speed: 0.7, Growth: 1.3, Detection: 0.5},
+		Rock:      TerrainEffect{Speed: 0.5, Growth: 0.2, Detection: 1.2},
+		Marsh:     TerrainEffect{Speed: 0.4, Growth: 0.8, Detection: 0.8},
+	}
+}
+
+func NewDefaultSpeciesConfig() map[string]Species {
+	species := make(map[string]Species)
+	for k, v := range SpeciesRegistry {
+		species[k] = v
+	}
+	return species
+}
+
 func NewDefaultEcosystemConfig() EcosystemConfig {
 	return EcosystemConfig{
-		Width:      Ecosystem_Width,
-		Movement:   NewDefaultMovementConfig(),
-		Population: NewDefaultPopulationConfig(),
-		Weather:    NewDefaultWeatherConfig(),
-		Lake:       NewDefaultLakeConfig(),
+		Workers:      1,
+		Width:        Ecosystem_Width,
+		Species:      NewDefaultSpeciesConfig(),
+		Interactions: DefaultInteractions(SpeciesRegistry),
+		Movement:     NewDefaultMovementConfig(),
+		Population:   NewDefaultPopulationConfig(),
+		Dynamics:     NewDefaultDynamicsConfig(),
+		Weather:      NewDefaultWeatherConfig(),
+		Lake:         NewDefaultLakeConfig(),
+		Plants:       NewDefaultPlantConfig(),
+		Vegetation:   NewDefaultVegetationConfig(),
+		Foraging:     NewDefaultForagingConfig(),
+		Energy:       NewDefaultEnergyConfig(),
+		Hydration:    NewDefaultHydrationConfig(),
+		Humans:       NewDefaultHumanConfig(),
+		Terrain:      NewDefaultTerrainConfig(),
 	}
 }
 
@@ -91,7 +361,32 @@ func (c EcosystemConfig) Clone() EcosystemConfig {
 	for k, v := range c.Population.InitialPopulations {
 		ip[k] = v
 	}
+	species := make(map[string]Species)
+	for k, v := range c.Species {
+		species[k] = v
+	}
 	c.Population.CarryingCapacities = cc
 	c.Population.InitialPopulations = ip
+	c.Species = species
+	c.Interactions = c.Interactions.Clone()
+	c.Terrain.Map = append([]string(nil), c.Terrain.Map...)
+	waters := make([]WaterConfig, len(c.Waters))
+	for i, w := range c.Waters {
+		w.Points = append([]OrderedPair(nil), w.Points...)
+		waters[i] = w
+	}
+	c.Waters = waters
 	return c
 }
+
+// defaultEcosystemConfig is used by ecosystems that were not built from a config,
+// such as the hand-made ecosystems in the tests.
+var defaultEcosystemConfig = NewDefaultEcosystemConfig()
+
+// settings returns the configuration the ecosystem runs with.
+func (e *Ecosystem) settings() *EcosystemConfig {
+	if e.config != nil {
+		return e.config
+	}
+	return &defaultEcosystemConfig
+}
@@ -1,5 +1,7 @@
 package main
 
+import "math/rand/v2"
+
 type Lake struct {
 	Radius    float64
 	MaxRadius float64     // The initial and maximum radius of the lake.
@@ -14,14 +16,29 @@ type Ecosystem struct {
 	Lake                 Lake   // Add the lake to the ecosystem
 	CarryingCapacity     map[string]int
 	weatherChangeCounter int
+	step                 int              // number of updates since the ecosystem was built
+	config               *EcosystemConfig // parameters the ecosystem was built from; nil means the defaults
+	rng                  *rand.Rand       // the ecosystem's own random stream, see random.go
+	rngSource            *rand.PCG
+	familyGrid           *spatialGrid // neighbour index, only set during UpdateEcosystem (see spatial.go)
+	plantGrid            *spatialGrid // plant index for foraging, set together with familyGrid
+	stepWater            *waterSet    // the water of the current step, only set during UpdateEcosystem (see water.go)
+	settlement           *Settlement  // home of the humans, nil until it is founded (see humans.go)
 }
 
 type Species struct {
-	Name              string
-	Type              string
-	Class             string
-	GrowthRate        float64
-	ContactGrowthRate float64
+	Name              string  `json:"name"`
+	Type              string  `json:"type"`
+	Class             string  `json:"class"`
+	GrowthRate        float64 `json:"growth_rate"`
+	ContactGrowthRate float64 `json:"contact_growth_rate"`
+	PerceptionRadius  float64 `json:"perception_radius"` // how far away predators see prey and prey see predators
+	CohesionWeight    float64 `json:"cohesion_weight"`   // pull toward the centre of nearby families of the same species
+	AlignmentWeight   float64 `json:"alignment_weight"`  // pull toward the heading of nearby families of the same species
+	MaturationAge     float64 `json:"maturation_age"`    // mean age at which juveniles become adults; 0 means newborns are adults
+	SenescenceAge     float64 `json:"senescence_age"`    // mean age at which adults become senescent
+	Lifespan          float64 `json:"lifespan"`          // mean age at death from old age; 0 means the species does not age
+	Fecundity         float64 `json:"fecundity"`         // births per adult relative to the family's birth rate
 }
 
 type Family struct {
@@ -32,6 +49,10 @@ type Family struct {
 	Acceleration        OrderedPair
 	PropulsionDirection OrderedPair // The family's internal "will to move" direction
 	species             Species
+	energy              float64            // energy reserve per individual (see energy.go)
+	hydration           float64            // hydration reserve per individual (see hydration.go)
+	stages              [numLifeStages]int // members per life stage, adding up to Size (see lifestages.go)
+	hunger              float64            // 0 when fed, 1 when starving; only grazers without an energy reserve (see foraging.go)
 }
 
 type OrderedPair struct {
@@ -42,20 +63,29 @@ type OrderedPair struct {
 type Plant struct {
 	position OrderedPair
 	size     float64
+	maxSize  float64 // biomass the plant grows toward in Sunny weather; 0 uses PlantConfig.MaxSize
 }
 
 var SpeciesRegistry = map[string]Species{
 	// RABBIT: Slight adjustments to keep them stable
-	"rabbit": {Name: "rabbit", Class: "prey", Type: "prey", GrowthRate: 0.02, ContactGrowthRate: -0.4},
-	"sheep":  {Name: "sheep", Class: "prey", Type: "prey", GrowthRate: 0.08, ContactGrowthRate: -0.1},
-	"deer":   {Name: "deer", Class: "prey", Type: "prey", GrowthRate: 0.06, ContactGrowthRate: -0.1},
+	"rabbit": {Name: "rabbit", Class: "prey", Type: "prey", GrowthRate: 0.02, ContactGrowthRate: -0.4, PerceptionRadius: 50, CohesionWeight: 0.5, AlignmentWeight: 0.5,
+		MaturationAge: 3, SenescenceAge: 15, Lifespan: 20, Fecundity: 2.0},
+	// Sheep and deer move in herds.
+	"sheep": {Name: "sheep", Class: "prey", Type: "prey", GrowthRate: 0.08, ContactGrowthRate: -0.1, PerceptionRadius: 40, CohesionWeight: 2.0, AlignmentWeight: 1.5,
+		MaturationAge: 6, SenescenceAge: 30, Lifespan: 40, Fecundity: 1.5},
+	"deer": {Name: "deer", Class: "prey", Type: "prey", GrowthRate: 0.06, ContactGrowthRate: -0.1, PerceptionRadius: 60, CohesionWeight: 1.5, AlignmentWeight: 1.5,
+		MaturationAge: 6, SenescenceAge: 30, Lifespan: 40, Fecundity: 1.5},
 
 	// WOLF FIX:
 	// GrowthRate: -0.02 (Starve slower, giving them time to find food)
 	// ContactGrowthRate: 0.5 (Big meal! When they eat, they grow significantly)
-	"wolf": {Name: "wolf", Class: "predator", Type: "predator", GrowthRate: -0.01, ContactGrowthRate: 0.3},
+	// PerceptionRadius: 80 (Wolves notice prey before the prey notices them)
+	// Wolves mature and age slower than their prey.
+	"wolf": {Name: "wolf", Class: "predator", Type: "predator", GrowthRate: -0.01, ContactGrowthRate: 0.3, PerceptionRadius: 80, CohesionWeight: 0.5, AlignmentWeight: 1.0,
+		MaturationAge: 8, SenescenceAge: 40, Lifespan: 50, Fecundity: 1.5},
 
-	"human": {Name: "human", Class: "neutral", Type: "neutral", GrowthRate: 0.0, ContactGrowthRate: 0.0},
+	// Humans have no age structure (no Lifespan). They hunt, farm and settle (see humans.go).
+	"human": {Name: "human", Class: "human", Type: "human", GrowthRate: 0.0, ContactGrowthRate: 0.0, PerceptionRadius: 100},
 }
 
 var initial_family_number = 3
@@ -70,6 +100,14 @@ var initialPopulations = map[string]int{
 	"human": 2,
 }
 
+// Default carrying capacity per species. Humans are not limited.
+var defaultCarryingCapacity = map[string]int{
+	"rabbit": 1200,
+	"sheep":  800,
+	"deer":   500,
+	"wolf":   150,
+}
+
 var Eating_Threshold = 15.0 // when distance is less than this value, predation can occur
 
 const Merging_Threshold = 20.0 // when distance is less than this value, families of the same species can merge
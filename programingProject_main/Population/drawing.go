@@ -36,17 +36,33 @@ func AnimateSystem(timePoints []Ecosystem, config Config, drawingFrequency int)
 func DrawToCanvas(ecosystem Ecosystem, config Config) image.Image {
 	c := canvas.CreateNewCanvas(config.CanvasWidth, config.CanvasWidth)
 
-	// Set background color and draw weather label based on the current ecosystem's weather.
+	// Set background color based on the current ecosystem's weather. A terrain
+	// map covers the background, so the weather is drawn over it as a label.
 	DrawWeatherBackground(&c, ecosystem.weather, config)
+	if terrain := ecosystem.settings().Terrain.Map; len(terrain) > 0 {
+		DrawTerrain(&c, terrain, config)
+		DrawWeatherLabel(&c, ecosystem.weather, config)
+	}
 
-	// Draw the lake
-	lakeColor := canvas.MakeColor(64, 164, 223) // A nice blue color for the lake
-	c.SetFillColor(lakeColor)
-	canvasX := (ecosystem.Lake.Position.x / ecosystem.width) * float64(config.CanvasWidth)
-	canvasY := (ecosystem.Lake.Position.y / ecosystem.width) * float64(config.CanvasWidth)
-	canvasRadius := (ecosystem.Lake.Radius / ecosystem.width) * float64(config.CanvasWidth)
-	c.Circle(canvasX, canvasY, canvasRadius)
-	c.Fill()
+	// Draw the lake and the other water. On a torus the water holds the copies
+	// of every body that sticks out over an edge of the world (see wrapWater),
+	// so it shows up again on the other side, as everything drawn below.
+	for _, body := range ecosystem.water() {
+		DrawWater(&c, body, config, ecosystem.width)
+	}
+
+	// Draw the human settlement as a brown disc the size of the area other species avoid
+	if s := ecosystem.settlement; s != nil {
+		c.SetFillColor(canvas.MakeColor(160, 110, 60))
+		radius := ecosystem.settings().Humans.SettlementRadius
+		for _, p := range ecosystem.drawImages(s.Position, radius) {
+			settlementX := (p.x / ecosystem.width) * float64(config.CanvasWidth)
+			settlementY := (p.y / ecosystem.width) * float64(config.CanvasWidth)
+			settlementRadius := (radius / ecosystem.width) * float64(config.CanvasWidth)
+			c.Circle(settlementX, settlementY, settlementRadius)
+			c.Fill()
+		}
+	}
 
 	// --- 植物繪製已根據需求停用 ---
 	// // Draw the plants
@@ -54,14 +70,100 @@ func DrawToCanvas(ecosystem Ecosystem, config Config) image.Image {
 	// 	DrawPlant(&c, p, config, ecosystem.width)
 	// }
 
+	// The margin is the largest shape DrawFamily draws, in world units.
+	margin := maxFamilyShape * ecosystem.width / float64(config.CanvasWidth)
 	for _, f := range ecosystem.Families {
 		// Draw each family
-		DrawFamily(&c, f, config, ecosystem.width)
+		for _, p := range ecosystem.drawImages(f.Position, margin) {
+			f.Position = p
+			DrawFamily(&c, f, config, ecosystem.width)
+		}
+	}
+	if ecosystem.boundary() == BoundaryReflect {
+		DrawWalls(&c, config)
 	}
 	// DrawLegend(c, config) // Temporarily disable the legend to remove the square at the top-left.
 	return c.GetImage()
 }
 
+// DrawTerrain paints the terrain map over the whole canvas, one rectangle per cell.
+func DrawTerrain(c *canvas.Canvas, rows []string, config Config) {
+	if len(rows) == 0 {
+		return
+	}
+	cols := len(rows[0])
+	for y, row := range rows {
+		y1 := y * config.CanvasWidth / len(rows)
+		y2 := (y + 1) * config.CanvasWidth / len(rows)
+		for x := 0; x < cols; x++ {
+			t, _ := terrainFromLetter(row[x])
+			col := terrainColors[t]
+			c.SetFillColor(canvas.MakeColor(col.R, col.G, col.B))
+			c.ClearRect(x*config.CanvasWidth/cols, y1, (x+1)*config.CanvasWidth/cols, y2)
+		}
+	}
+}
+
+// drawImages returns where a shape reaching radius around p is drawn: at p,
+// and on a torus also at its copies across the edges (see torusImages).
+func (e *Ecosystem) drawImages(p OrderedPair, radius float64) []OrderedPair {
+	if e.boundary() != BoundaryTorus {
+		return []OrderedPair{p}
+	}
+	return torusImages(p, e.width, radius)
+}
+
+// DrawWater draws a pond, a polygonal lake or a river in the colour of the lake.
+func DrawWater(c *canvas.Canvas, body WaterBody, config Config, ecosystemWidth float64) {
+	scale := float64(config.CanvasWidth) / ecosystemWidth
+	lakeColor := canvas.MakeColor(64, 164, 223)
+	switch w := body.(type) {
+	case Lake:
+		c.SetFillColor(lakeColor)
+		c.Circle(w.Position.x*scale, w.Position.y*scale, w.Radius*scale)
+		c.Fill()
+	case Polygon:
+		if len(w.Points) < 3 {
+			return
+		}
+		c.SetFillColor(lakeColor)
+		c.MoveTo(w.Points[0].x*scale, w.Points[0].y*scale)
+		for _, p := range w.Points[1:] {
+			c.LineTo(p.x*scale, p.y*scale)
+		}
+		c.LineTo(w.Points[0].x*scale, w.Points[0].y*scale)
+		c.Fill()
+	case River:
+		if len(w.Points) < 2 {
+			return
+		}
+		c.SetStrokeColor(lakeColor)
+		c.SetLineWidth(w.Width * scale)
+		c.MoveTo(w.Points[0].x*scale, w.Points[0].y*scale)
+		for _, p := range w.Points[1:] {
+			c.LineTo(p.x*scale, p.y*scale)
+		}
+		c.Stroke()
+	}
+}
+
+// maxFamilyShape is the radius in pixels of the largest shape DrawFamily
+// draws: the star of a human family of the maximum size.
+const maxFamilyShape = 40.0
+
+// DrawWalls outlines the canvas to show that the edges of the world are walls.
+func DrawWalls(c *canvas.Canvas, config Config) {
+	w := float64(config.CanvasWidth)
+	c.SetStrokeColor(canvas.MakeColor(60, 40, 20))
+	c.SetLineWidth(4)
+	c.MoveTo(0, 0)
+	c.LineTo(w, 0)
+	c.LineTo(w, w)
+	c.LineTo(0, w)
+	c.LineTo(0, 0)
+	c.Stroke()
+}
+
 // DrawPlant draws a single plant on the canvas.
 func DrawPlant(c *canvas.Canvas, p Plant, config Config, ecosystemWidth float64) {
 	// We can represent plants as small green circles.
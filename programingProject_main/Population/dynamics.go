@@ -0,0 +1,203 @@
+package main
+
+// A PopulationModel decides how fast every family grows or shrinks. The
+// spatial part of a step (movement, grazing, who meets whom) is the same for
+// every model, so runs with different models and the same seed can be compared
+// directly. The model is chosen with Dynamics.Model in the config:
+//
+//	default         the rule the simulation was built with (see functions.go)
+//	discrete        the original rule of UpdatePopulations: species rate, a flat
+//	                plant bonus for prey and contacts
+//	lotka_volterra  exponential growth and mass action predation
+//	holling_ii      logistic prey and a Holling type II (saturating) response
+//	holling_iii     logistic prey and a Holling type III (sigmoid) response
+//	beverton_holt   Beverton–Holt density dependence and mass action predation
+//
+// Except in the discrete model, predators catch prey individuals (see
+// predation.go) and the prey caught turn into predators.
+type PopulationModel interface {
+	// Response is the functional response the predators hunt with, see FunctionalResponse.
+	Response() int
+	// GrowthRates returns the per capita rates of every family.
+	GrowthRates(eco *Ecosystem, step PopulationStep) []FamilyRates
+}
+
+// FamilyRates are the per capita rates of one family per unit of time. Growth
+// is the family's own rate: births when it is positive, which only the adults
+// give (see lifestages.go), and deaths among all members when it is negative.
+// Deaths are the members lost on top of that, to predators, to contacts with
+// other families or to thirst, and always hit every member.
+type FamilyRates struct {
+	Growth float64
+	Deaths float64
+}
+
+// PopulationStep is what the models get to know about a step.
+type PopulationStep struct {
+	TimeStep          float64
+	ConsumedPlantMass map[int]float64 // plant mass each prey family ate, see ConsumePlants
+	Neighbours        [][]int         // families within the eating threshold of each family
+	Caught            []float64       // prey each family caught per unit of time (see Predation)
+	Lost              []float64       // members each family lost to predators per unit of time
+}
+
+type defaultModel struct{}
+
+type discreteModel struct{}
+
+// predationModel covers the textbook predator-prey models, which differ in
+// how a species' own growth slows down with density and in the functional
+// response of the predators.
+type predationModel struct {
+	density  func(rate, count, capacity float64) float64
+	response int // 1 for mass action, 2 and 3 for Holling type II and III
+}
+
+var populationModels = map[string]PopulationModel{
+	"default":        defaultModel{},
+	"discrete":       discreteModel{},
+	"lotka_volterra": predationModel{density: exponentialGrowth, response: 1},
+	"holling_ii":     predationModel{density: logisticGrowth, response: 2},
+	"holling_iii":    predationModel{density: logisticGrowth, response: 3},
+	"beverton_holt":  predationModel{density: bevertonHoltGrowth, response: 1},
+}
+
+// populationModel returns the model named in the config, or the default model.
+func (c *EcosystemConfig) populationModel() PopulationModel {
+	if m, ok := populationModels[c.Dynamics.Model]; ok {
+		return m
+	}
+	return defaultModel{}
+}
+
+func (defaultModel) Response() int { return 2 }
+
+func (discreteModel) Response() int { return 1 }
+
+func (m predationModel) Response() int { return m.response }
+
+// newPopulationStep finds the families within the eating threshold of each
+// other and works out what the predators among them catch.
+func newPopulationStep(eco *Ecosystem, consumedPlantMass map[int]float64, response int, timeStep float64) PopulationStep {
+	cfg := eco.settings()
+	threshold := cfg.Population.EatingThreshold
+	step := PopulationStep{
+		TimeStep:          timeStep,
+		ConsumedPlantMass: consumedPlantMass,
+		Neighbours:        make([][]int, len(eco.Families)),
+	}
+
+	// Only families in the grid cells around each family can be close enough to meet.
+	// Every family collects its own neighbours, so the workers never write the same slot.
+	grid := newSpatialGrid(familyPositions(eco.Families), eco.width, threshold)
+	parallelChunks(eco.workers(), len(eco.Families), func(_, lo, hi int) {
+		var nearby []int
+		for i := lo; i < hi; i++ {
+			nearby = grid.near(eco.Families[i].Position, threshold, nearby)
+			for _, j := range nearby {
+				if j == i || eco.distanceBetween(eco.Families[i].Position, eco.Families[j].Position) >= threshold {
+					continue
+				}
+				step.Neighbours[i] = append(step.Neighbours[i], j)
+			}
+		}
+	})
+	step.Caught, step.Lost = Predation(eco.Families, step.Neighbours, cfg.Interactions, cfg.Dynamics.HandlingTime, response, timeStep)
+	return step
+}
+
+// GrowthRates gives every family its species rate plus the flat contact rates
+// from Check; prey get Dynamics.PreyPlantBonus on top, whatever they ate.
+func (discreteModel) GrowthRates(eco *Ecosystem, step PopulationStep) []FamilyRates {
+	cfg := eco.settings()
+	threshold := cfg.Population.EatingThreshold
+	rates := make([]FamilyRates, len(eco.Families))
+	for i, f := range eco.Families {
+		rates[i].Growth = f.species.GrowthRate
+		for _, j := range step.Neighbours[i] {
+			// A contact that costs the family members is a death, one that
+			// gains it members is a birth.
+			if contactGR, _ := Check(f, eco.Families[j], threshold, cfg.Interactions, eco.width, eco.boundary()); contactGR < 0 {
+				rates[i].Deaths -= contactGR
+			} else {
+				rates[i].Growth += contactGR
+			}
+		}
+		if f.species.eatsPlants() {
+			rates[i].Growth += cfg.Dynamics.PreyPlantBonus
+		}
+	}
+	return rates
+}
+
+// GrowthRates gives every family its species rate, slowed down by density,
+// plus the predators born from the prey caught and minus the prey lost.
+func (m predationModel) GrowthRates(eco *Ecosystem, step PopulationStep) []FamilyRates {
+	cfg := eco.settings()
+	counts := competingCounts(CountSpecies(eco), cfg.Interactions)
+	families := eco.Families
+
+	rates := make([]FamilyRates, len(families))
+	for i, f := range families {
+		gr := f.species.GrowthRate
+		// Density only slows down growth; a dying population dies at its own rate.
+		if capacity, ok := eco.CarryingCapacity[f.species.Name]; ok && capacity > 0 && gr > 0 {
+			gr = m.density(gr, counts[f.species.Name], float64(capacity))
+		}
+		predation := predationRate(f, step.Caught[i], step.Lost[i], cfg.Dynamics)
+		rates[i] = FamilyRates{Growth: gr + predation.Growth, Deaths: predation.Deaths}
+	}
+	return rates
+}
+
+// predationRate turns the prey a family caught and the members it lost per
+// unit of time into per capita rates: members lost are deaths, and every prey
+// caught gives ConversionEfficiency newborns. Humans do not breed from what
+// they hunt (see humans.go).
+func predationRate(f Family, caught, lost float64, d DynamicsConfig) FamilyRates {
+	if f.Size <= 0 {
+		return FamilyRates{}
+	}
+	if f.species.isHuman() {
+		caught = 0
+	}
+	return FamilyRates{
+		Growth: d.ConversionEfficiency * caught / float64(f.Size),
+		Deaths: lost / float64(f.Size),
+	}
+}
+
+// FunctionalResponse returns the prey one predator catches per unit of time
+// when n prey are within its reach: a*n for type 1 (mass action),
+// a*n/(1+a*h*n) for Holling type II and a*n²/(1+a*h*n²) for type III.
+func FunctionalResponse(n, attackRate, handlingTime float64, kind int) float64 {
+	switch kind {
+	case 2:
+		return attackRate * n / (1 + attackRate*handlingTime*n)
+	case 3:
+		return attackRate * n * n / (1 + attackRate*handlingTime*n*n)
+	default:
+		return attackRate * n
+	}
+}
+
+// exponentialGrowth ignores density.
+func exponentialGrowth(rate, count, capacity float64) float64 {
+	return rate
+}
+
+// logisticGrowth falls linearly to zero at the carrying capacity.
+func logisticGrowth(rate, count, capacity float64) float64 {
+	return rate * (1 - count/capacity)
+}
+
+// bevertonHoltGrowth is the per capita rate of the Beverton–Holt map
+// n' = (1+r)n / (1+r*n/K), which approaches the capacity without overshooting it.
+func bevertonHoltGrowth(rate, count, capacity float64) float64 {
+	return rate * (1 - count/capacity) / (1 + rate*count/capacity)
+}
+
+// populationModelNames returns the names Dynamics.Model accepts, sorted.
+func populationModelNames() []string {
+	return sortedKeys(populationModels)
+}
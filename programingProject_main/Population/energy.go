@@ -0,0 +1,79 @@
+package main
+
+// Every prey and predator family carries an energy reserve per individual.
+// Moving fast and living in a large family burn energy; eating refills it,
+// plant mass for prey and kills for predators. A family low on energy starves
+// and a family with plenty of it has young, so a pack of wolves that fails to
+// hunt dies off instead of shrinking at a fixed rate. Neutral and human
+// families keep the fixed growth rate of their species.
+
+// usesEnergy reports whether the family's population is driven by its energy reserve.
+func usesEnergy(f Family, cfg EnergyConfig) bool {
+	return cfg.Enabled && f.species.Type != "neutral" && !f.species.isHuman()
+}
+
+// starvation is 0 for a family with a full energy reserve and 1 for an empty one.
+func (f Family) starvation(cfg EnergyConfig) float64 {
+	if cfg.Max <= 0 {
+		return 0
+	}
+	return min(1, max(0, 1-f.energy/cfg.Max))
+}
+
+// EnergyUse returns the energy one individual of the family burns per unit of
+// time: the base cost plus costs that grow with speed and with family size.
+func EnergyUse(f Family, cfg EnergyConfig, maxSpeed float64, maxFamilySize int) float64 {
+	use := cfg.BaseCost
+	if maxSpeed > 0 {
+		use += cfg.SpeedCost * NormOrdered(f.MovementSpeed) / maxSpeed
+	}
+	if maxFamilySize > 0 {
+		use += cfg.SizeCost * float64(f.Size) / float64(maxFamilySize)
+	}
+	return use
+}
+
+// UpdateEnergy burns a step's worth of energy in every family and adds what
+// it ate: consumedPlantMass is the plant mass each family grazed (see
+// ConsumePlants) and caught the prey each family caught per unit of time (see
+// Predation).
+func UpdateEnergy(ecosystem *Ecosystem, consumedPlantMass map[int]float64, caught []float64, timeStep float64) {
+	cfg := ecosystem.settings()
+	e := cfg.Energy
+	for i := range ecosystem.Families {
+		f := &ecosystem.Families[i]
+		if !usesEnergy(*f, e) || f.Size <= 0 {
+			continue
+		}
+		gain := consumedPlantMass[i] * e.PlantEnergy / float64(f.Size)
+		if caught[i] > 0 {
+			gain += caught[i] / float64(f.Size) * e.KillEnergy * timeStep
+		}
+		f.energy += gain - EnergyUse(*f, e, cfg.Movement.MaxSpeed, cfg.Population.MaxFamilySize)*timeStep
+		f.energy = min(e.Max, max(0, f.energy))
+	}
+}
+
+// EnergyGrowthRate returns the per capita growth rate an energy reserve leads
+// to: deaths that grow linearly as the reserve drops below the starvation
+// threshold, births that grow linearly as it rises above the reproduction
+// threshold, and no change in between.
+func EnergyGrowthRate(f Family, cfg EnergyConfig) float64 {
+	switch {
+	case f.energy < cfg.StarvationThreshold:
+		return -cfg.StarvationRate * (1 - f.energy/cfg.StarvationThreshold)
+	case f.energy > cfg.ReproductionThreshold:
+		return cfg.ReproductionRate * (f.energy - cfg.ReproductionThreshold) / (cfg.Max - cfg.ReproductionThreshold)
+	default:
+		return 0
+	}
+}
+
+// payForBirths takes the energy for `births` newborns out of the family's
+// reserve, which is shared among all members including the newborns.
+func payForBirths(f *Family, births int, cfg EnergyConfig) {
+	if births <= 0 || f.Size <= 0 {
+		return
+	}
+	f.energy = max(0, f.energy-cfg.ReproductionCost*float64(births)/float64(f.Size))
+}
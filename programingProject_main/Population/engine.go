@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image"
+	"io"
+	"programingProject_main/gifhelper"
+)
+
+// StepObserver receives the ecosystem at every step of a streaming run.
+// The ecosystem passed to Observe is the live state and keeps changing after
+// Observe returns, so an observer must copy whatever it wants to keep.
+type StepObserver interface {
+	Observe(step int, ecosystem *Ecosystem) error
+}
+
+// StepFinisher is implemented by observers that need to flush or close
+// something once the run is over.
+type StepFinisher interface {
+	Finish() error
+}
+
+// ObserverFunc adapts a plain function to the StepObserver interface.
+type ObserverFunc func(step int, ecosystem *Ecosystem) error
+
+func (f ObserverFunc) Observe(step int, ecosystem *Ecosystem) error {
+	return f(step, ecosystem)
+}
+
+// RunSimulation advances the ecosystem numSteps times in place. The observers
+// see the state after every update, so only one Ecosystem is ever alive and
+// memory does not grow with the run length. A new ecosystem is also observed
+// once before the first update as step 0; an ecosystem resumed from a
+// checkpoint was already observed at its current step by the original run.
+func RunSimulation(ecosystem *Ecosystem, numSteps int, timeStep float64, observers ...StepObserver) error {
+	notify := func(step int) error {
+		for _, o := range observers {
+			if err := o.Observe(step, ecosystem); err != nil {
+				return fmt.Errorf("step %d: %w", step, err)
+			}
+		}
+		return nil
+	}
+
+	if ecosystem.step == 0 {
+		if err := notify(0); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < numSteps; i++ {
+		UpdateEcosystem(ecosystem, timeStep)
+		if err := notify(ecosystem.step); err != nil {
+			return err
+		}
+	}
+
+	for _, o := range observers {
+		if f, ok := o.(StepFinisher); ok {
+			if err := f.Finish(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CSVObserver streams the population log (population_log.csv format) to a writer.
+type CSVObserver struct {
+	writer        *csv.Writer
+	every         int
+	headerWritten bool
+}
+
+// NewCSVObserver writes a log row every `every` steps to w.
+func NewCSVObserver(w io.Writer, every int) *CSVObserver {
+	if every < 1 {
+		every = 1
+	}
+	return &CSVObserver{writer: csv.NewWriter(w), every: every}
+}
+
+// WithoutHeader makes the observer append to an existing log that already has a header.
+func (o *CSVObserver) WithoutHeader() *CSVObserver {
+	o.headerWritten = true
+	return o
+}
+
+func (o *CSVObserver) Observe(step int, ecosystem *Ecosystem) error {
+	if !o.headerWritten {
+		if err := o.writer.Write(PopulationLogHeader(ecosystem.settings())); err != nil {
+			return err
+		}
+		o.headerWritten = true
+	}
+	if step%o.every != 0 {
+		return nil
+	}
+	return o.writer.Write(PopulationLogRow(step, ecosystem))
+}
+
+func (o *CSVObserver) Finish() error {
+	o.writer.Flush()
+	return o.writer.Error()
+}
+
+// FrameObserver draws every n-th step for the GIF animation. Frames are
+// converted to paletted images right away, which is what the GIF needs and
+// keeps them small.
+type FrameObserver struct {
+	config Config
+	every  int
+	frames []image.Image
+}
+
+func NewFrameObserver(config Config, every int) *FrameObserver {
+	if every < 1 {
+		every = 1
+	}
+	return &FrameObserver{config: config, every: every}
+}
+
+func (o *FrameObserver) Observe(step int, ecosystem *Ecosystem) error {
+	if step%o.every == 0 {
+		o.frames = append(o.frames, gifhelper.ImageToPaletted(DrawToCanvas(*ecosystem, o.config)))
+	}
+	return nil
+}
+
+// Frames returns the frames drawn so far.
+func (o *FrameObserver) Frames() []image.Image {
+	return o.frames
+}
+
+// MetricsObserver records a PopulationSnapshot every n-th step.
+type MetricsObserver struct {
+	Series EcosystemStateSeries
+	every  int
+}
+
+func NewMetricsObserver(every int) *MetricsObserver {
+	if every < 1 {
+		every = 1
+	}
+	return &MetricsObserver{every: every}
+}
+
+func (o *MetricsObserver) Observe(step int, ecosystem *Ecosystem) error {
+	if step%o.every == 0 {
+		o.Series.Append(NewPopulationSnapshot(step, ecosystem))
+	}
+	return nil
+}
+
+// ConsoleObserver prints a one-line population summary every n-th step.
+type ConsoleObserver struct {
+	out   io.Writer
+	every int
+}
+
+func NewConsoleObserver(out io.Writer, every int) *ConsoleObserver {
+	if every < 1 {
+		every = 1
+	}
+	return &ConsoleObserver{out: out, every: every}
+}
+
+func (o *ConsoleObserver) Observe(step int, ecosystem *Ecosystem) error {
+	if step%o.every != 0 {
+		return nil
+	}
+	_, err := fmt.Fprintln(o.out, FormatPopulationLine(step, ecosystem))
+	return err
+}
@@ -0,0 +1,61 @@
+package main
+
+// Grazers (prey and omnivores) do not just graze whatever they wander over:
+// they sense the plant mass within Foraging.Radius and steer toward where most
+// of it is. The pull grows with hunger. A family that lives on its energy
+// reserve is as hungry as the reserve is low (see energy.go); any other
+// grazer keeps a hunger counter that rises every step and falls with every meal.
+
+// ForagingForce points from a grazing family toward the mass-weighted centre of
+// the plants it can sense, scaled so a centre at the edge of the radius has
+// unit strength. It is zero for other families and when no plant is in range.
+func ForagingForce(ecosystem *Ecosystem, i int) OrderedPair {
+	f := ecosystem.Families[i]
+	radius := ecosystem.settings().Foraging.Radius
+	if !f.species.eatsPlants() || radius <= 0 {
+		return OrderedPair{}
+	}
+
+	var pull OrderedPair
+	mass := 0.0
+	for _, pi := range ecosystem.plantsNear(f.Position, radius, nil) {
+		p := ecosystem.Plants[pi]
+		toPlant := ecosystem.offset(f.Position, p.position)
+		if p.size <= 0 || NormOrdered(toPlant) > radius {
+			continue
+		}
+		pull = AddOrdered(pull, ScaleOrdered(toPlant, p.size))
+		mass += p.size
+	}
+	if mass == 0 {
+		return OrderedPair{}
+	}
+	return ScaleOrdered(pull, 1/(mass*radius))
+}
+
+// ForagingWeight is the weight of the foraging force for family i: the base
+// weight for a fed family, rising to Weight+HungerWeight for a starving one.
+func ForagingWeight(ecosystem *Ecosystem, i int) float64 {
+	cfg := ecosystem.settings()
+	f := ecosystem.Families[i]
+	hunger := f.hunger
+	if usesEnergy(f, cfg.Energy) {
+		hunger = f.starvation(cfg.Energy)
+	}
+	return cfg.Foraging.Weight + cfg.Foraging.HungerWeight*hunger
+}
+
+// UpdateHunger makes every grazing family without an energy reserve hungrier
+// by HungerRate per unit of time and feeds it the plant mass it ate this step
+// (as returned by ConsumePlants).
+func UpdateHunger(ecosystem *Ecosystem, consumedPlantMass map[int]float64, timeStep float64) {
+	cfg := ecosystem.settings()
+	for i := range ecosystem.Families {
+		f := &ecosystem.Families[i]
+		if !f.species.eatsPlants() || usesEnergy(*f, cfg.Energy) {
+			continue
+		}
+		f.hunger += cfg.Foraging.HungerRate*timeStep - consumedPlantMass[i]*cfg.Foraging.Satiety
+		f.hunger = min(1, max(0, f.hunger))
+	}
+}
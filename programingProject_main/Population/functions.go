@@ -2,10 +2,11 @@ package main
 
 import (
 	"math"
-	"math/rand"
+	"math/rand/v2"
+	"slices"
 )
 
-func UpdateAcceleration(ecosystem *Ecosystem, i int) OrderedPair {
+func UpdateAcceleration(ecosystem *Ecosystem, i int, rng *rand.Rand) OrderedPair {
 	// 1. Calculate the separation force to avoid crowding.
 	forceX, forceY := CalculateSeparationForce(ecosystem, i)
 
@@ -16,12 +17,12 @@ func UpdateAcceleration(ecosystem *Ecosystem, i int) OrderedPair {
 	// b. If the family has nearly stopped, force it to pick a new random direction.
 	currentSpeedMag := math.Hypot(ecosystem.Families[i].MovementSpeed.x, ecosystem.Families[i].MovementSpeed.y)
 	if currentSpeedMag < 0.1 { // Threshold for being "stuck"
-		newAngle := rand.Float64() * 2 * math.Pi
+		newAngle := rng.Float64() * 2 * math.Pi
 		propulsionDir = OrderedPair{x: math.Cos(newAngle), y: math.Sin(newAngle)}
 	} else {
 		// c. If moving, apply a small, random turn to the propulsion direction to make it wander smoothly.
 		turnStrength := 0.3 // How sharply the propulsion direction can change per step.
-		angleChange := (rand.Float64()*2 - 1) * turnStrength
+		angleChange := (rng.Float64()*2 - 1) * turnStrength
 		cos := math.Cos(angleChange)
 		sin := math.Sin(angleChange)
 		newPropulsionX := propulsionDir.x*cos - propulsionDir.y*sin
@@ -29,14 +30,11 @@ func UpdateAcceleration(ecosystem *Ecosystem, i int) OrderedPair {
 		propulsionDir = OrderedPair{x: newPropulsionX, y: newPropulsionY}
 	}
 
-	ecosystem.Families[i].PropulsionDirection = propulsionDir
-
 	// c. Calculate the final propulsion force based on the new direction.
 	propulsionStrength := 3.0 // The magnitude of the "gas pedal".
 	propulsionX := propulsionDir.x * propulsionStrength
 	propulsionY := propulsionDir.y * propulsionStrength
 
-	// 3. The final acceleration is the sum of the propulsion force and the separation force.
 	// CRITICAL FIX: For neutral species like humans who may not have other families to interact with,
 	// we need to ensure their propulsion force is strong enough to guarantee movement.
 	if ecosystem.Families[i].species.Type == "neutral" {
@@ -44,23 +42,47 @@ func UpdateAcceleration(ecosystem *Ecosystem, i int) OrderedPair {
 		return OrderedPair{x: propulsionX * 2.0, y: propulsionY * 2.0}
 	}
 
-	separationWeight := 2.0
-	return OrderedPair{x: propulsionX + forceX*separationWeight, y: propulsionY + forceY*separationWeight}
+	// 3. The final acceleration is the sum of the propulsion force, the separation force,
+	// the steering forces (predators pursue prey, prey evade predators), the
+	// flocking forces toward families of the same species, for prey the
+	// foraging force toward plant mass, which grows with hunger, the water
+	// force toward the nearest shore, which grows with thirst, and the
+	// settlement force (humans head home, everyone else keeps away).
+	movement := ecosystem.settings().Movement
+	species := ecosystem.Families[i].species
+	pursuit := PursuitForce(ecosystem, i)
+	evasion := EvasionForce(ecosystem, i)
+	cohesion := CohesionForce(ecosystem, i)
+	alignment := AlignmentForce(ecosystem, i)
+	foraging := ForagingForce(ecosystem, i)
+	foragingWeight := ForagingWeight(ecosystem, i)
+	settlement := SettlementForce(ecosystem, i)
+	settlementWeight := SettlementWeight(ecosystem, i)
+	toWater := WaterForce(ecosystem, i)
+	waterWeight := WaterWeight(ecosystem, i)
+	return OrderedPair{
+		x: propulsionX + forceX*movement.SeparationWeight + pursuit.x*movement.PursuitWeight + evasion.x*movement.EvasionWeight +
+			cohesion.x*species.CohesionWeight + alignment.x*species.AlignmentWeight + foraging.x*foragingWeight + settlement.x*settlementWeight +
+			toWater.x*waterWeight,
+		y: propulsionY + forceY*movement.SeparationWeight + pursuit.y*movement.PursuitWeight + evasion.y*movement.EvasionWeight +
+			cohesion.y*species.CohesionWeight + alignment.y*species.AlignmentWeight + foraging.y*foragingWeight + settlement.y*settlementWeight +
+			toWater.y*waterWeight,
+	}
 }
 
 // UpdatePropulsionDirection calculates the new propulsion direction for the next frame.
-func UpdatePropulsionDirection(f Family) OrderedPair {
+func UpdatePropulsionDirection(f Family, rng *rand.Rand) OrderedPair {
 	propulsionDir := f.PropulsionDirection
 
 	// If the family has nearly stopped, force it to pick a new random direction.
 	currentSpeedMag := math.Hypot(f.MovementSpeed.x, f.MovementSpeed.y)
 	if currentSpeedMag < 0.1 { // Threshold for being "stuck"
-		newAngle := rand.Float64() * 2 * math.Pi
+		newAngle := rng.Float64() * 2 * math.Pi
 		propulsionDir = OrderedPair{x: math.Cos(newAngle), y: math.Sin(newAngle)}
 	} else {
 		// If moving, apply a small, random turn to the propulsion direction to make it wander smoothly.
 		turnStrength := 0.3 // How sharply the propulsion direction can change per step.
-		angleChange := (rand.Float64()*2 - 1) * turnStrength
+		angleChange := (rng.Float64()*2 - 1) * turnStrength
 		cos := math.Cos(angleChange)
 		sin := math.Sin(angleChange)
 		newPropulsionX := propulsionDir.x*cos - propulsionDir.y*sin
@@ -71,46 +93,34 @@ func UpdatePropulsionDirection(f Family) OrderedPair {
 }
 
 // CalculateSeparationForce computes the separation force exerted on a family by all its neighbors.
-// The strength of the force depends on the family's own species type.
+// A family keeps its distance from every neighbour, and more so from the species it avoids
+// (see interactions.go).
 func CalculateSeparationForce(ecosystem *Ecosystem, i int) (float64, float64) {
 	// Fx = C_separation * (x1-x2) / d^2
 	// Fy = C_separation * (y1-y2) / d^2
+	// C_separation = 1 + the family's avoidance of the neighbour's species
 
-	var separationCoefficient float64
-	familyType := ecosystem.Families[i].species.Type
-
-	// Determine the separation coefficient based on the current family's type.
-	switch familyType {
-	case "predator":
-		separationCoefficient = 1.5 // CsepPred
-	case "prey":
-		separationCoefficient = 1.0 // CsepPrey
-	case "neutral":
-		separationCoefficient = 1.0 // CsepNeutral
-	default:
-		separationCoefficient = 1.0
-	}
-
-	dthreshold := Separation_Threshold // proximity threshold
+	interactions := ecosystem.settings().Interactions
+	dthreshold := ecosystem.settings().Movement.SeparationThreshold // proximity threshold
 	currentFamily := ecosystem.Families[i]
-	x1 := currentFamily.Position.x
-	y1 := currentFamily.Position.y
 
 	SepSumX := 0.0
 	SepSumY := 0.0
 	n := 0.0
 
-	// Iterate over all other families to calculate the total force
-	for j, otherFamily := range ecosystem.Families {
+	// Iterate over the nearby families to calculate the total force
+	for _, j := range ecosystem.familiesNear(currentFamily.Position, dthreshold, nil) {
 		if i == j {
 			continue
 		}
-		x2 := otherFamily.Position.x
-		y2 := otherFamily.Position.y
-		dx := x1 - x2
-		dy := y1 - y2
+		otherFamily := ecosystem.Families[j]
+		// On a torus a neighbour across the edge of the world is close by too (see boundary.go).
+		away := ecosystem.offset(otherFamily.Position, currentFamily.Position)
+		dx := away.x
+		dy := away.y
 		d := math.Sqrt(dx*dx + dy*dy)
 		if d < dthreshold && d > 0 { // d > 0 to avoid division by zero
+			separationCoefficient := 1 + interactions.Between(currentFamily.species.Name, otherFamily.species.Name).Avoidance
 			forceMagnitude := separationCoefficient / (d * d)
 			SepSumX += dx * forceMagnitude
 			SepSumY += dy * forceMagnitude
@@ -150,7 +160,21 @@ func UpdateVelocity(f Family, oldAcceleration OrderedPair, newAcceleration Order
 
 }
 
+// UpdatePosition moves the family on a torus: positions leaving the world
+// come back on the other side. See MoveFamily for the other boundary modes.
 func UpdatePosition(f Family, oldAcceleration OrderedPair, oldVelocity OrderedPair, ecosystemWidth, timeStep float64) OrderedPair {
+	return WrapPosition(nextPosition(f, oldAcceleration, oldVelocity, timeStep), ecosystemWidth)
+}
+
+// MoveFamily moves the family and applies the boundary of the world (see
+// boundary.go). It returns the new position and velocity, and false when the
+// family left the world.
+func MoveFamily(f Family, oldAcceleration OrderedPair, oldVelocity OrderedPair, ecosystemWidth, timeStep float64, boundary string) (OrderedPair, OrderedPair, bool) {
+	return ApplyBoundary(nextPosition(f, oldAcceleration, oldVelocity, timeStep), oldVelocity, ecosystemWidth, boundary)
+}
+
+// nextPosition is where the family ends up after timeStep, before the boundary is applied.
+func nextPosition(f Family, oldAcceleration OrderedPair, oldVelocity OrderedPair, timeStep float64) OrderedPair {
 	//px(n+1)=(1/2)ax(n)*t^2+vx(n)*t+px(n)
 	//py(n+1)=(1/2)ay(n)*t^2+vy(n)*t+py(n)
 	oldAx := oldAcceleration.x
@@ -159,90 +183,124 @@ func UpdatePosition(f Family, oldAcceleration OrderedPair, oldVelocity OrderedPa
 	oldVy := oldVelocity.y
 	Px := (0.5)*((oldAx)*timeStep*timeStep) + (oldVx * timeStep) + f.Position.x
 	Py := (0.5)*((oldAy)*timeStep*timeStep) + (oldVy * timeStep) + f.Position.y
-	for Px > ecosystemWidth {
-		Px = Px - ecosystemWidth
-	}
-	for Px < 0 {
-		Px = ecosystemWidth + Px
-	}
-	for Py > ecosystemWidth {
-		Py = Py - ecosystemWidth
-	}
-	for Py < 0 {
-		Py = ecosystemWidth + Py
-	}
-
 	return OrderedPair{x: Px, y: Py}
 }
 
+// updateFamilyPopulations works out the growth rate of every family with the
+// configured population model (see dynamics.go) and applies it.
 func updateFamilyPopulations(eco *Ecosystem, consumedPlantMass map[int]float64, timeStep float64) {
-	// growthRates calculation is the same...
-	growthRates := make([]float64, len(eco.Families))
-	currentCounts := CountSpecies(eco)
+	cfg := eco.settings()
+	model := cfg.populationModel()
+	step := newPopulationStep(eco, consumedPlantMass, model.Response(), timeStep)
+
+	// What a family ate goes into its energy reserve in every model, so the
+	// families forage the same way whatever model decides on their numbers.
+	if cfg.Energy.Enabled {
+		UpdateEnergy(eco, consumedPlantMass, step.Caught, timeStep)
+	}
+	rates := model.GrowthRates(eco, step)
+	// Thirst kills in whichever model the config selects, the discrete one
+	// included. Only UpdatePopulations, which runs the discrete model outside
+	// the simulation step, leaves thirst out.
+	if cfg.Hydration.Enabled {
+		UpdateHydration(eco, timeStep)
+		for i, f := range eco.Families {
+			if needsWater(f, cfg.Hydration) {
+				rates[i].Deaths -= HydrationGrowthRate(f, cfg.Hydration)
+			}
+		}
+	}
+	applyGrowthRates(eco, rates, timeStep)
+}
 
-	// Step 1: Base Growth
+// GrowthRates is the rule the simulation was built with: species growth rates
+// changed by the weather and held back by the carrying capacity, or the energy
+// reserve where it is enabled, plus plants eaten and predation with a Holling
+// type II response.
+func (defaultModel) GrowthRates(eco *Ecosystem, step PopulationStep) []FamilyRates {
+	rates := make([]FamilyRates, len(eco.Families))
+	cfg := eco.settings()
+	// Competitors count against a species' carrying capacity too (see interactions.go).
+	currentCounts := competingCounts(CountSpecies(eco), cfg.Interactions)
+
+	// Step 1: Base Growth. Families that live on their energy reserve get their
+	// births and deaths in step 2 instead.
 	for i := range eco.Families {
 		f := eco.Families[i]
-		gr := f.species.GrowthRate * (1.0 + CoefficientOfAnimalGrowthRateIncrease(eco.weather))
+		gr := 0.0
+		if !usesEnergy(f, cfg.Energy) {
+			gr = f.species.GrowthRate * (1.0 + CoefficientOfAnimalGrowthRateIncrease(eco.weather))
 
-		if capacity, ok := eco.CarryingCapacity[f.species.Name]; ok && capacity > 0 {
-			gr *= (1.0 - float64(currentCounts[f.species.Name])/float64(capacity))
-		}
+			if capacity, ok := eco.CarryingCapacity[f.species.Name]; ok && capacity > 0 {
+				gr *= (1.0 - currentCounts[f.species.Name]/float64(capacity))
+			}
 
-		if f.species.Type == "prey" {
-			gr += consumedPlantMass[i] * PlantGrowthConversionFactor
-		}
-		if IsInLake(f.Position, eco.Lake) {
-			gr += 0.1
+			if f.species.eatsPlants() {
+				gr += step.ConsumedPlantMass[i] * cfg.Plants.ConversionFactor
+			}
 		}
-		growthRates[i] = gr
+		rates[i].Growth = gr
 	}
 
-	// Step 2: Interactions (Ensure you REMOVED the " * Size" multiplier here as discussed before!)
-	for i := 0; i < len(eco.Families); i++ {
-		for j := i + 1; j < len(eco.Families); j++ {
-			contactGR_A, contactGR_B := Check(eco.Families[i], eco.Families[j])
-			growthRates[i] += contactGR_A
-			growthRates[j] += contactGR_B
+	// Step 2: Predation and energy. The energy reserve decides on births and
+	// deaths, so a predator's catch only counts through its reserve; losses to
+	// predators stay deaths.
+	for i, f := range eco.Families {
+		if !usesEnergy(f, cfg.Energy) {
+			predation := predationRate(f, step.Caught[i], step.Lost[i], cfg.Dynamics)
+			rates[i].Growth += predation.Growth
+			rates[i].Deaths = predation.Deaths
+			continue
+		}
+		gr := EnergyGrowthRate(f, cfg.Energy)
+		if capacity, ok := eco.CarryingCapacity[f.species.Name]; ok && capacity > 0 && gr > 0 {
+			gr *= math.Max(0, 1.0-currentCounts[f.species.Name]/float64(capacity))
 		}
+		rates[i].Growth += gr
+		rates[i].Deaths = predationRate(f, 0, step.Lost[i], cfg.Dynamics).Deaths
 	}
+	return rates
+}
+
+// applyGrowthRates changes the size of every family by its per capita rates
+// over timeStep, lets its members age and removes the families that died out.
+func applyGrowthRates(eco *Ecosystem, rates []FamilyRates, timeStep float64) {
+	cfg := eco.settings()
+	rng := eco.random()
 
-	// Step 3: Apply changes with Probabilistic Rounding
+	// Apply changes with Probabilistic Rounding
 	for i := range eco.Families {
+		r := rates[i]
+
+		// 1. Births only come from the adults (see lifestages.go), deaths hit every member.
 		size := float64(eco.Families[i].Size)
+		growthSize := size
+		if r.Growth > 0 {
+			growthSize = eco.Families[i].breedingSize()
+		}
 
-		// 1. Calculate exact fractional change needed
+		// 2. Calculate exact fractional change needed
 		// Multiply by timeStep to scale properly
-		change := size * growthRates[i] * timeStep
-
-		// 2. Separate integer and fractional parts
-		intChange := int(change)                            // e.g., -0.35 -> 0
-		fracChange := math.Abs(change - float64(intChange)) // e.g., 0.35
-
-		// 3. Probabilistic Rounding
-		// If random number < 0.35, we add the extra +/- 1
-		if rand.Float64() < fracChange {
-			if change > 0 {
-				intChange += 1
-			} else {
-				intChange -= 1
-			}
-		}
+		change := (growthSize*r.Growth - size*r.Deaths) * timeStep
+
+		// 3. Probabilistic Rounding: -0.35 becomes -1 with a chance of 0.35 and 0 otherwise
+		intChange := probabilisticRound(change, rng)
 
 		// 4. Apply
-		if eco.Families[i].Size == 1 && growthRates[i] < 0 {
+		if eco.Families[i].Size == 1 && change < 0 {
 			// If the random roll is < 0.10, kill it.
 			// This prevents the "0.5% chance to die" immortality bug.
-			if rand.Float64() < 0.10 {
+			if rng.Float64() < 0.10 {
 				intChange = -1
 			}
 		}
-		eco.Families[i].Size += intChange
-
-		// Prevent negative size
-		if eco.Families[i].Size < 0 {
-			eco.Families[i].Size = 0
+		eco.Families[i].addMembers(intChange, rng)
+		if usesEnergy(eco.Families[i], cfg.Energy) {
+			payForBirths(&eco.Families[i], intChange, cfg.Energy)
 		}
+
+		// 5. Members grow up, grow old and die of old age
+		AgeFamily(&eco.Families[i], timeStep, rng)
 	}
 
 	// Remove extinct families... (Keep existing logic)
@@ -257,12 +315,34 @@ func updateFamilyPopulations(eco *Ecosystem, consumedPlantMass map[int]float64,
 	// Carrying capacity enforcement... (Keep existing logic)
 }
 
+// PushFamiliesAshore moves every family in the water to just outside the
+// nearest shore. Water that touches an edge of the world can push a family
+// over it, so the boundary is applied again: the family is wrapped around or
+// bounced back in, or leaves the world at an absorbing edge.
+func PushFamiliesAshore(ecosystem *Ecosystem) {
+	water := ecosystem.water()
+	boundary := ecosystem.boundary()
+	ashore := ecosystem.Families[:0]
+	for _, f := range ecosystem.Families {
+		var stays bool
+		f.Position, f.MovementSpeed, stays = ApplyBoundary(PushOutOfWater(f.Position, water), f.MovementSpeed, ecosystem.width, boundary)
+		if stays {
+			ashore = append(ashore, f)
+		}
+	}
+	ecosystem.Families = ashore
+}
+
 func UpdateEcosystem(ecosystem *Ecosystem, timeStep float64) {
+	cfg := ecosystem.settings()
+
 	// 1. Update Weather periodically.
-	ecosystem.weatherChangeCounter++
-	if ecosystem.weatherChangeCounter >= Weather_Change_Interval {
-		ecosystem.UpdateWeather()
-		ecosystem.weatherChangeCounter = 0 // Reset the counter
+	if cfg.Weather.Enabled {
+		ecosystem.weatherChangeCounter++
+		if ecosystem.weatherChangeCounter >= cfg.Weather.StepInterval {
+			ecosystem.UpdateWeather()
+			ecosystem.weatherChangeCounter = 0 // Reset the counter
+		}
 	}
 
 	// 2. Update Lake size based on weather and push out any families caught inside.
@@ -275,52 +355,82 @@ func UpdateEcosystem(ecosystem *Ecosystem, timeStep float64) {
 	baseRatio := 0.8 // Corresponds to Sunny/Frozen weather
 	ecosystem.Lake.Radius = ecosystem.Lake.MaxRadius * (baseRatio + lakeRadiusCoeff)
 
-	// After the lake resizes, check if any family is now inside and push them out.
-	for i := range ecosystem.Families {
-		ecosystem.Families[i].Position = PushOutOfLake(ecosystem.Families[i].Position, ecosystem.Lake)
-	}
+	// The water stays as it is for the rest of the step.
+	ecosystem.indexWater()
 
-	// First, update family movement and physics
-	updatedFamilies := make([]Family, len(ecosystem.Families))
+	// After the lake resizes, check if any family is now in the water and push them out.
+	PushFamiliesAshore(ecosystem)
+	water := ecosystem.water()
+	boundary := ecosystem.boundary()
 
-	for i, f := range ecosystem.Families {
-		oldAcceleration := f.Acceleration // Use the stored acceleration from the previous step
-		// The acceleration calculation now only reads state, it doesn't change it.
-		newAcceleration := UpdateAcceleration(ecosystem, i)
-		newVelocity := UpdateVelocity(f, oldAcceleration, newAcceleration, Max_Family_Speed, timeStep, ecosystem.weather)
-		newPosition := UpdatePosition(f, newAcceleration, newVelocity, ecosystem.width, timeStep) // Calculate potential new position
-
-		// Check if the next position is inside the lake. If so, treat it as a collision.
-		if IsInLake(newPosition, ecosystem.Lake) {
-			// Reverse the velocity to "bounce" off the lake.
-			newVelocity.x *= -1
-			newVelocity.y *= -1
-			// Recalculate the position based on the bounced velocity to prevent entering the lake.
-			newPosition = UpdatePosition(f, newAcceleration, newVelocity, ecosystem.width, timeStep)
-		}
+	// Index the families and plants so the movement forces only look at their neighbours.
+	ecosystem.indexFamilies(cfg.Movement.SeparationThreshold)
+	ecosystem.indexPlants(cfg.Foraging.Radius)
 
-		// Decide the *next* frame's propulsion direction based on the *current* state.
-		nextPropulsionDirection := UpdatePropulsionDirection(f)
-
-		updatedFamilies[i] = Family{
-			Size:                f.Size,
-			MovementSpeed:       newVelocity,
-			Position:            newPosition,
-			MovementDirection:   newVelocity,
-			Acceleration:        newAcceleration,         // Store the new acceleration for the next step
-			PropulsionDirection: nextPropulsionDirection, // Store the NEWLY decided direction for the next frame.
-			species:             f.species,
+	// First, update family movement and physics. Each family only reads the
+	// current state and writes its own slot, so the families are split over the workers.
+	updatedFamilies := make([]Family, len(ecosystem.Families))
+	inWorld := make([]bool, len(ecosystem.Families))
+	streams := ecosystem.workerStreams()
+
+	parallelChunks(len(streams), len(ecosystem.Families), func(worker, lo, hi int) {
+		rng := streams[worker]
+		for i := lo; i < hi; i++ {
+			f := ecosystem.Families[i]
+			oldAcceleration := f.Acceleration // Use the stored acceleration from the previous step
+			// The acceleration calculation now only reads state, it doesn't change it.
+			newAcceleration := UpdateAcceleration(ecosystem, i, rng)
+			// Rough terrain lowers the speed limit (see terrain.go).
+			maxSpeed := cfg.Movement.MaxSpeed * cfg.Terrain.EffectAt(f.Position, ecosystem.width).Speed
+			newVelocity := UpdateVelocity(f, oldAcceleration, newAcceleration, maxSpeed, timeStep, ecosystem.weather)
+			// Calculate potential new position
+			newPosition, newVelocity, stays := MoveFamily(f, newAcceleration, newVelocity, ecosystem.width, timeStep, boundary)
+
+			// Check if the next position is in the water. If so, treat it as a collision.
+			if stays && water.Contains(newPosition) {
+				// Reflect the velocity off the shore to "bounce" off the water.
+				_, normal := water.Shore(newPosition)
+				newVelocity = BounceOffShore(newVelocity, normal)
+				// Recalculate the position based on the bounced velocity to prevent entering the water.
+				newPosition, newVelocity, stays = MoveFamily(f, newAcceleration, newVelocity, ecosystem.width, timeStep, boundary)
+			}
+			inWorld[i] = stays
+
+			// Decide the *next* frame's propulsion direction based on the *current* state.
+			nextPropulsionDirection := UpdatePropulsionDirection(f, rng)
+
+			updatedFamilies[i] = Family{
+				Size:                f.Size,
+				MovementSpeed:       newVelocity,
+				Position:            newPosition,
+				MovementDirection:   newVelocity,
+				Acceleration:        newAcceleration,         // Store the new acceleration for the next step
+				PropulsionDirection: nextPropulsionDirection, // Store the NEWLY decided direction for the next frame.
+				species:             f.species,
+				energy:              f.energy,
+				hydration:           f.hydration,
+				stages:              f.stages,
+				hunger:              f.hunger,
+			}
+		}
+	})
+	// Families that crossed an absorbing edge have migrated out of the world.
+	ecosystem.Families = updatedFamilies[:0]
+	for i, f := range updatedFamilies {
+		if inWorld[i] {
+			ecosystem.Families = append(ecosystem.Families, f)
 		}
 	}
-	ecosystem.Families = updatedFamilies
+	ecosystem.familyGrid = nil // the families moved, so the grid is out of date
+	ecosystem.plantGrid = nil
 
-	// 3. Update Plants (Growth and Consumption)
-	// Plant growth with weather effect
-	plantGrowthCoeff := 1.0 + CoefficientOfPlantIncrease(ecosystem.weather)
-	ecosystem.Plants = PlantGrowth(ecosystem.Plants, plantGrowthCoeff)
+	// 3. Update Plants (Growth, spreading and frost, then farming and Consumption)
+	UpdateVegetation(ecosystem)
+	UpdateHumans(ecosystem)
 
 	// 獵物消耗植物，並記錄每個家族的消耗量
-	consumedMass := ConsumePlants(ecosystem, consumptionRate, Eating_Threshold)
+	consumedMass := ConsumePlants(ecosystem, cfg.Plants.ConsumptionRate, cfg.Population.EatingThreshold)
+	UpdateHunger(ecosystem, consumedMass, timeStep)
 
 	// 4. Update Animal Populations based on interactions and environment
 	updateFamilyPopulations(ecosystem, consumedMass, timeStep)
@@ -330,13 +440,19 @@ func UpdateEcosystem(ecosystem *Ecosystem, timeStep float64) {
 
 	// 6. Merge small families
 	MergeFamilies(ecosystem)
+
+	ecosystem.stepWater = nil // the lake changes with the weather of the next step
+	ecosystem.step++
 }
 
+// SimulateEcosystem keeps every state of the run. Each state is a Clone of the
+// previous one, so later updates never change a recorded state. Use
+// RunSimulation for long runs that do not need the history.
 func SimulateEcosystem(initialEcosystem Ecosystem, numGens int, timeStep float64) []Ecosystem {
 	updatedEcosystem := make([]Ecosystem, numGens)
-	updatedEcosystem[0] = initialEcosystem
+	updatedEcosystem[0] = initialEcosystem.Clone()
 	for i := 1; i < len(updatedEcosystem); i++ {
-		nextState := updatedEcosystem[i-1]
+		nextState := updatedEcosystem[i-1].Clone()
 		UpdateEcosystem(&nextState, timeStep)
 		updatedEcosystem[i] = nextState
 	}
@@ -352,80 +468,110 @@ func CountSpecies(ecosystem *Ecosystem) map[string]int {
 	return counts
 }
 
-func InitFamilies(speciesName string, totalPopulation int, initialSpeed, ecosystemWidth float64) []Family {
-	numFamilies := initial_family_number
-	if totalPopulation < numFamilies {
-		numFamilies = totalPopulation
-	}
-
-	families := make([]Family, numFamilies)
-	s := SpeciesRegistry[speciesName]
-
-	// Distribute population among families
-	baseSize := totalPopulation / numFamilies
-	remainder := totalPopulation % numFamilies
-
-	for i := 0; i < numFamilies; i++ {
-		familySize := baseSize
-		if i < remainder {
-			familySize++
-		}
-
-		x := rand.Float64() * ecosystemWidth
-		y := rand.Float64() * ecosystemWidth
-		angle := rand.Float64() * 2 * math.Pi
-		vx := initialSpeed * math.Cos(angle)
-		vy := initialSpeed * math.Sin(angle)
-
-		families[i] = Family{
-			Size:              familySize,
-			MovementSpeed:     OrderedPair{x: vx, y: vy},
-			Position:          OrderedPair{x: x, y: y},
-			MovementDirection: OrderedPair{x: vx, y: vy},
-			Acceleration:      OrderedPair{x: 0, y: 0}, // Initialize acceleration to zero
-			species:           s,
-		}
-	}
-	return families
+// InitFamilies creates the starting families of one species of the default
+// config: totalPopulation members in all, moving at initialSpeed in a world of
+// the given width without water. It goes the same way as the families of a
+// scenario (see initializeFamilies).
+func InitFamilies(speciesName string, totalPopulation int, initialSpeed, ecosystemWidth float64, rng *rand.Rand) []Family {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Width = ecosystemWidth
+	cfg.Movement.InitialSpeed = initialSpeed
+	cfg.Population.InitialPopulations = map[string]int{speciesName: totalPopulation}
+	return initializeFamilies(&cfg, Waters{}, rng)
 }
 
+// ConsumePlants lets every grazing family (see eatsPlants) graze the plants within threshold of it
+// and returns the plant mass each prey family ate. The plants are split over
+// the workers; a plant shared by several families is eaten by them in family
+// order, exactly as if each family grazed in turn.
 func ConsumePlants(ecosystem *Ecosystem, consumptionRate float64, threshold float64) map[int]float64 {
-	consumedMassByFamily := make(map[int]float64)
-
-	for fi := range ecosystem.Families {
-		f := &ecosystem.Families[fi]
-		if f.species.Type == "prey" { // only prey eat plants
-			totalConsumed := 0.0
-			for pi := range ecosystem.Plants {
-				if ecosystem.Plants[pi].size > 0 {
-					dx := ecosystem.Plants[pi].position.x - f.Position.x
-					dy := ecosystem.Plants[pi].position.y - f.Position.y
-					d := math.Sqrt(dx*dx + dy*dy)
-					if d < threshold {
-						eatenAmount := consumptionRate
-						if ecosystem.Plants[pi].size < eatenAmount {
-							eatenAmount = ecosystem.Plants[pi].size
-						}
-						ecosystem.Plants[pi].size -= eatenAmount
-						totalConsumed += eatenAmount
+	var prey []int
+	var preyPositions []OrderedPair
+	for fi, f := range ecosystem.Families {
+		if f.species.eatsPlants() {
+			prey = append(prey, fi)
+			preyPositions = append(preyPositions, f.Position)
+		}
+	}
+	// Index the prey so each plant only checks the families around it.
+	grid := newSpatialGrid(preyPositions, ecosystem.width, threshold)
+
+	workers := ecosystem.workers()
+	eatenByWorker := make([][]float64, workers)
+	parallelChunks(workers, len(ecosystem.Plants), func(worker, lo, hi int) {
+		eaten := make([]float64, len(prey))
+		var nearby []int
+		for pi := lo; pi < hi; pi++ {
+			plant := &ecosystem.Plants[pi]
+			if plant.size <= 0 {
+				continue
+			}
+			nearby = grid.near(plant.position, threshold, nearby)
+			slices.Sort(nearby)
+			for _, k := range nearby {
+				f := ecosystem.Families[prey[k]]
+				d := ecosystem.distanceBetween(f.Position, plant.position)
+				if d < threshold && plant.size > 0 {
+					eatenAmount := consumptionRate
+					if plant.size < eatenAmount {
+						eatenAmount = plant.size
 					}
+					plant.size -= eatenAmount
+					eaten[k] += eatenAmount
 				}
 			}
-			consumedMassByFamily[fi] = totalConsumed
 		}
+		eatenByWorker[worker] = eaten
+	})
+
+	consumedMassByFamily := make(map[int]float64, len(prey))
+	for k, fi := range prey {
+		totalConsumed := 0.0
+		for _, eaten := range eatenByWorker {
+			totalConsumed += eaten[k]
+		}
+		consumedMassByFamily[fi] = totalConsumed
 	}
 	return consumedMassByFamily
 }
 
-func PlantGrowth(plants []Plant, growthCoeff float64) []Plant {
+// PlantGrowth grows every plant logistically toward its maximum biomass. The
+// weather coefficient scales both the growth rate and the maximum, so in bad
+// weather plants above the lowered maximum die back. A dead plant regrows from
+// seed with probability RegrowthChance per step.
+func PlantGrowth(plants []Plant, cfg PlantConfig, growthCoeff float64, rng *rand.Rand) []Plant {
 	for i := range plants {
-		if plants[i].size > 0 {
-			plants[i].size += PlantCoefficient * plants[i].size * growthCoeff
-		}
+		growPlant(&plants[i], cfg, growthCoeff, growthCoeff, rng)
 	}
 	return plants
 }
 
+// growPlant is one step of PlantGrowth for a single plant, with separate
+// multipliers for the growth rate and for the maximum biomass. Where the
+// maximum drops to zero there is nothing left to die back toward, so the plant
+// dies, as the logistic step does for a maximum just above zero.
+func growPlant(p *Plant, cfg PlantConfig, rateCoeff, capacityCoeff float64, rng *rand.Rand) {
+	if p.size <= 0 {
+		if rng.Float64() < cfg.RegrowthChance {
+			p.size = cfg.SeedSize
+		}
+		return
+	}
+	capacity := p.maxSize
+	if capacity <= 0 {
+		capacity = cfg.MaxSize
+	}
+	capacity *= capacityCoeff
+	if capacity <= 0 {
+		p.size = 0
+		return
+	}
+	p.size += cfg.GrowthCoefficient * rateCoeff * p.size * (1 - p.size/capacity)
+	if p.size < 0 {
+		p.size = 0
+	}
+}
+
 // PlantGrowthConversionFactor: How much growth rate 1 unit of plant mass provides.
 // This is a new constant you can tune.
 const PlantGrowthConversionFactor = 0.5
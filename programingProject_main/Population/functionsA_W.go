@@ -2,40 +2,49 @@ package main
 
 import (
 	"math"
-	"math/rand"
+	"math/rand/v2"
 	"sort"
 )
 
-// function to initialize an ecosystem with initial populations and families.
+// function to initialize an ecosystem with the default populations and families.
 func InitializeEcosystem() Ecosystem {
-	width := Ecosystem_Width
-	var families []Family
+	return BuildEcosystemFromConfig(NewDefaultEcosystemConfig())
+}
 
-	// CRITICAL FIX: Initialize the lake *before* creating families that need to check its position.
-	lake := InitializeLake(250, 250, 75) // Center (250,250), Radius 75
+// maxSpawnAttempts is how many random spots a family tries before giving up
+// on finding dry land to start on.
+const maxSpawnAttempts = 10000
+
+// initializeFamilies creates the starting families of every species listed in the config.
+func initializeFamilies(cfg *EcosystemConfig, water WaterBody, rng *rand.Rand) []Family {
+	width := cfg.Width
+	var families []Family
 
-	// Initialize animal families from the initialPopulations map
-	for speciesName, totalPopulation := range initialPopulations { // Now includes humans
-		speciesData := SpeciesRegistry[speciesName]
-		familySizes := randomPartition(totalPopulation, initial_family_number, Smallest_Family_Size)
+	// Species are visited in name order so the families always come out in the same order.
+	for _, speciesName := range sortedKeys(cfg.Population.InitialPopulations) {
+		totalPopulation := cfg.Population.InitialPopulations[speciesName]
+		speciesData := cfg.Species[speciesName]
+		familySizes := randomPartition(totalPopulation, cfg.Population.FamiliesPerSpecies, cfg.Population.MinFamilySize, rng)
 		for _, size := range familySizes {
-			// Increase initial speed to make movement more visible from the start.
-			initialSpeedMagnitude := 10.0 // You can adjust this value
-
-			var pos OrderedPair
-			// Ensure families do not spawn inside the lake.
-			for {
-				pos = OrderedPair{x: rand.Float64() * width, y: rand.Float64() * width}
-				if !IsInLake(pos, lake) {
-					break // Found a valid position, exit the loop.
-				}
+			initialSpeedMagnitude := cfg.Movement.InitialSpeed
+
+			// Ensure families do not spawn in the water. A family that finds no
+			// dry land in maxSpawnAttempts tries is left out; Validate rejects a
+			// world without dry land, so that only happens when very little is left.
+			pos, ok := OrderedPair{}, false
+			for attempt := 0; attempt < maxSpawnAttempts && !ok; attempt++ {
+				pos = OrderedPair{x: rng.Float64() * width, y: rng.Float64() * width}
+				ok = !water.Contains(pos)
+			}
+			if !ok {
+				continue
 			}
 
-			angle := rand.Float64() * 2 * math.Pi // Generate a random direction
+			angle := rng.Float64() * 2 * math.Pi // Generate a random direction
 			speed := OrderedPair{x: initialSpeedMagnitude * math.Cos(angle), y: initialSpeedMagnitude * math.Sin(angle)}
 
 			// Initialize the propulsion direction to a random unit vector
-			propulsionAngle := rand.Float64() * 2 * math.Pi
+			propulsionAngle := rng.Float64() * 2 * math.Pi
 			propulsionDir := OrderedPair{x: math.Cos(propulsionAngle), y: math.Sin(propulsionAngle)}
 
 			families = append(families, Family{
@@ -46,37 +55,32 @@ func InitializeEcosystem() Ecosystem {
 				Acceleration:        OrderedPair{x: 0, y: 0}, // Initialize acceleration to zero
 				PropulsionDirection: propulsionDir,
 				species:             speciesData,
+				energy:              cfg.Energy.Initial,
+				hydration:           cfg.Hydration.Initial,
+				stages:              initialStages(size, speciesData),
 			})
 		}
 	}
+	return families
+}
 
-	// Initialize plants
+// initializePlants scatters the configured number of plants outside the water.
+func initializePlants(cfg *EcosystemConfig, water WaterBody, rng *rand.Rand) []Plant {
+	width := cfg.Width
 	var plants []Plant
-	numPlants := 200 // Let's start with 200 plants
-	for i := 0; i < numPlants; i++ {
-		pos := OrderedPair{x: rand.Float64() * width, y: rand.Float64() * width}
-		// Ensure plants do not spawn inside the lake.
-		if !IsInLake(pos, lake) {
-			plants = append(plants, Plant{position: pos, size: rand.Float64()*10 + 5}) // Random initial size
+	sizeRange := cfg.Plants.MaxInitialSize - cfg.Plants.MinInitialSize
+	for i := 0; i < cfg.Plants.Count; i++ {
+		pos := OrderedPair{x: rng.Float64() * width, y: rng.Float64() * width}
+		// Ensure plants do not spawn in the water.
+		if !water.Contains(pos) {
+			plants = append(plants, Plant{position: pos, size: rng.Float64()*sizeRange + cfg.Plants.MinInitialSize, maxSize: cfg.Plants.MaxSize}) // Random initial size
 		}
 	}
-
-	return Ecosystem{
-		Families: families,
-		Lake:     lake,
-		Plants:   plants, // Add the initialized plants
-		width:    width,
-		CarryingCapacity: map[string]int{
-			"rabbit": 1200,
-			"sheep":  800,
-			"deer":   500,
-			"wolf":   150,
-		},
-	}
+	return plants
 }
 
 // Help function to initialize family sizes randomly
-func randomPartition(total, k, min int) []int {
+func randomPartition(total, k, min int, rng *rand.Rand) []int {
 	if k <= 0 || total < k*min {
 		// If the total population is too small to partition,
 		// put all individuals into a single family, provided the total is not zero.
@@ -92,7 +96,7 @@ func randomPartition(total, k, min int) []int {
 	// 2. Generate k-1 random cut points between 0 and remain
 	cuts := make([]int, k-1)
 	for i := range cuts {
-		cuts[i] = rand.Intn(remain + 1)
+		cuts[i] = rng.IntN(remain + 1)
 	}
 
 	// 3. Sort the cut points and add 0 and remain as the boundaries
@@ -109,60 +113,39 @@ func randomPartition(total, k, min int) []int {
 	return parts
 }
 
-// function to update populations based on growth rates
+// UpdatePopulations applies the discrete population model (see dynamics.go)
+// to the ecosystem as one whole unit of time.
 func UpdatePopulations(ecosystem *Ecosystem) {
 	if len(ecosystem.Families) == 0 {
 		return
 	}
-
-	growthRates := make([]float64, len(ecosystem.Families))
-
-	// Step 1: Calculate base growth rates (natural growth, plant consumption)
-	for i, f := range ecosystem.Families {
-		gr := f.species.GrowthRate
-		if ecosystem.Families[i].species.Type == "prey" {
-			gr += PlantCoefficient
-		}
-		growthRates[i] = gr
-	}
-
-	// Step 2: Add growth rates from pairwise interactions (predation)
-	for i := 0; i < len(ecosystem.Families); i++ {
-		for j := i + 1; j < len(ecosystem.Families); j++ {
-			contactGR_A, contactGR_B := Check(ecosystem.Families[i], ecosystem.Families[j])
-			growthRates[i] += contactGR_A
-			growthRates[j] += contactGR_B
-		}
-	}
-
-	// Step 3: Apply the final calculated growth rates to update family sizes
-	for i := range ecosystem.Families {
-		size := float64(ecosystem.Families[i].Size)
-		newSize := int(math.Round(size * (1.0 + growthRates[i])))
-		ecosystem.Families[i].Size = newSize
-	}
-
-	// Step 4: Remove extinct families (size <= 0)
-	compacted := ecosystem.Families[:0]
-	for _, f := range ecosystem.Families {
-		if f.Size > 0 {
-			compacted = append(compacted, f)
-		}
-	}
-	ecosystem.Families = compacted
+	model := discreteModel{}
+	step := newPopulationStep(ecosystem, nil, model.Response(), 1)
+	applyGrowthRates(ecosystem, model.GrowthRates(ecosystem, step), 1)
 }
 
 // function to merge small family with someone nearby
 func MergeFamilies(ecosystem *Ecosystem) {
+	minSize := ecosystem.settings().Population.MinFamilySize
+	threshold := ecosystem.settings().Population.MergingThreshold
 	f := ecosystem.Families
 	for i := 0; i < len(f); {
-		if f[i].Size < Smallest_Family_Size {
+		if f[i].Size < minSize {
 			merged := false
 			for j := 0; j < len(f); j++ {
 				if i == j || f[i].species.Name != f[j].species.Name {
 					continue
 				}
-				if distance(f[i].Position, f[j].Position) <= Merging_Threshold {
+				if ecosystem.distanceBetween(f[i].Position, f[j].Position) <= threshold {
+					// The merged family pools the energy and water of its members
+					// and is as hungry as they are on average.
+					total := float64(f[i].Size + f[j].Size)
+					if total > 0 {
+						f[j].energy = (f[i].energy*float64(f[i].Size) + f[j].energy*float64(f[j].Size)) / total
+						f[j].hydration = (f[i].hydration*float64(f[i].Size) + f[j].hydration*float64(f[j].Size)) / total
+						f[j].hunger = (f[i].hunger*float64(f[i].Size) + f[j].hunger*float64(f[j].Size)) / total
+					}
+					f[j].stages = mergeStages(f[i], f[j])
 					f[j].Size += f[i].Size
 					f[i] = f[len(f)-1]
 					f = f[:len(f)-1]
@@ -179,27 +162,34 @@ func MergeFamilies(ecosystem *Ecosystem) {
 	ecosystem.Families = f
 }
 
-// SplitLargeFamilies checks for families that have grown larger than Max_Family_Size and splits them.
+// SplitLargeFamilies checks for families that have grown larger than the configured maximum size and splits them.
 func SplitLargeFamilies(ecosystem *Ecosystem) {
+	maxSize := ecosystem.settings().Population.MaxFamilySize
 	// We must build a new slice because we are both modifying existing families (size) and adding new ones.
 	var nextGenerationFamilies []Family
+	rng := ecosystem.random()
 
 	for i := range ecosystem.Families {
 		f := &ecosystem.Families[i] // Use a pointer to modify the original family
 
-		if f.Size > Max_Family_Size {
-			// This family needs to be split.
-			originalNewSize := f.Size / 2
-			splitNewSize := f.Size - originalNewSize
+		if f.Size > maxSize {
+			// This family needs to be split, each half taking half of every life stage.
+			keptStages, newStages := splitStages(f.StageCounts())
+			originalNewSize, splitNewSize := 0, 0
+			for s := range keptStages {
+				originalNewSize += keptStages[s]
+				splitNewSize += newStages[s]
+			}
 
 			// Update the original family's size.
 			f.Size = originalNewSize
+			f.stages = keptStages
 
 			// --- Give both families a large, opposing VELOCITY to push them apart ---
 			// This is more effective than acceleration as it's an immediate change in speed,
 			// and won't be overwritten by the next frame's acceleration calculation.
 			splitSpeedBoost := 30.0 // A large speed boost.
-			angle := rand.Float64() * 2 * math.Pi
+			angle := rng.Float64() * 2 * math.Pi
 			pushVx := splitSpeedBoost * math.Cos(angle)
 			pushVy := splitSpeedBoost * math.Sin(angle)
 
@@ -207,15 +197,31 @@ func SplitLargeFamilies(ecosystem *Ecosystem) {
 			f.MovementSpeed.x += pushVx
 			f.MovementSpeed.y += pushVy
 
+			// The new family gets pushed in the opposite direction, from a slight
+			// offset that can take it over an edge of the world.
+			velocity := OrderedPair{x: f.MovementSpeed.x - 2*pushVx, y: f.MovementSpeed.y - 2*pushVy}
+			position := OrderedPair{x: f.Position.x + (rng.Float64()*2 - 1), y: f.Position.y + (rng.Float64()*2 - 1)}
+			if ecosystem.width > 0 {
+				var inWorld bool
+				position, velocity, inWorld = ApplyBoundary(position, velocity, ecosystem.width, ecosystem.boundary())
+				if !inWorld {
+					// An absorbing edge does not take half a family; it stays with its parent.
+					position = f.Position
+				}
+			}
+
 			// Create the new family, inheriting properties from the parent.
 			newFamily := Family{
-				Size: splitNewSize,
-				// The new family gets pushed in the opposite direction.
-				MovementSpeed:     OrderedPair{x: f.MovementSpeed.x - 2*pushVx, y: f.MovementSpeed.y - 2*pushVy},
-				Position:          OrderedPair{x: f.Position.x + (rand.Float64()*2 - 1), y: f.Position.y + (rand.Float64()*2 - 1)}, // Slight offset
+				Size:              splitNewSize,
+				MovementSpeed:     velocity,
+				Position:          position,
 				MovementDirection: f.MovementDirection,
 				Acceleration:      f.Acceleration, // Inherit acceleration
 				species:           f.species,
+				energy:            f.energy,
+				hydration:         f.hydration,
+				stages:            newStages,
+				hunger:            f.hunger,
 			}
 			nextGenerationFamilies = append(nextGenerationFamilies, newFamily)
 		}
@@ -224,18 +230,23 @@ func SplitLargeFamilies(ecosystem *Ecosystem) {
 	ecosystem.Families = append(ecosystem.Families, nextGenerationFamilies...)
 }
 
+// distance is the straight-line distance between two points. Distances
+// between families and plants follow the boundary of the world instead; see
+// Ecosystem.distanceBetween.
 func distance(a, b OrderedPair) float64 {
 	return math.Hypot(a.x-b.x, a.y-b.y)
 }
 
-func Check(A, B Family) (float64, float64) {
-	if distance(A.Position, B.Position) < Eating_Threshold {
-		// Case 1: A is predator, B is prey
-		if A.species.Type == "predator" && B.species.Type == "prey" {
-			return A.species.ContactGrowthRate, B.species.ContactGrowthRate
-		}
-		// Case 2: B is predator, A is prey
-		if B.species.Type == "predator" && A.species.Type == "prey" {
+// Check returns the contact growth rates of A and B when one of them eats the
+// other (see interactions.go) and they are closer than the eating threshold,
+// measured in a world of the given width and boundary (see Offset). Only the
+// discrete population model still uses these flat rates; the others count
+// prey caught (see predation.go).
+func Check(A, B Family, eatingThreshold float64, interactions InteractionMatrix, width float64, boundary string) (float64, float64) {
+	if NormOrdered(Offset(A.Position, B.Position, width, boundary)) < eatingThreshold {
+		// Case 1: A eats B. Case 2: B eats A.
+		if interactions.Between(A.species.Name, B.species.Name).Predation > 0 ||
+			interactions.Between(B.species.Name, A.species.Name).Predation > 0 {
 			return A.species.ContactGrowthRate, B.species.ContactGrowthRate
 		}
 	}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"math/rand"
 	"programingProject_main/canvas"
 )
 
@@ -10,7 +9,7 @@ import (
 // function to update weather randomly
 func (e *Ecosystem) UpdateWeather() {
 	choices := []string{"Dry", "Sunny", "Rainy", "Frozen"}
-	e.weather = choices[rand.Intn(len(choices))]
+	e.weather = choices[e.random().IntN(len(choices))]
 }
 
 // functions to get coefficients of plant increasing based on weather, when using, multiply the base rate with (1 + coefficient)
@@ -70,20 +69,37 @@ func CoefficientOfAnimalGrowthRateIncrease(weather string) float64 {
 	}
 }
 
-// function to draw weather background and label on the canvas, can be called inside the function DrawToCanvas in drawing.go
-func DrawWeatherBackground(c *canvas.Canvas, weather string, config Config) {
-	var col Color
+// functions to get coefficients of thirst increase based on weather, when using, multiply the base rate with (1 + coefficient)
+func CoefficientOfThirstIncrease(weather string) float64 {
+	switch weather {
+	case "Dry":
+		return 1.00
+	case "Sunny":
+		return 0.50
+	case "Rainy":
+		return -0.20
+	default: // "Frozen"
+		return -0.20
+	}
+}
+
+// function to pick the colour that shows the weather on the canvas
+func weatherColor(weather string) Color {
 	switch weather {
 	case "Frozen":
-		col = Color{0, 0, 164, 255} // darker blue
+		return Color{0, 0, 164, 255} // darker blue
 	case "Sunny":
-		col = Color{253, 112, 43, 158} // orange
+		return Color{253, 112, 43, 158} // orange
 	case "Rainy":
-		col = Color{74, 106, 125, 158} // grayish blue
+		return Color{74, 106, 125, 158} // grayish blue
 	default: // Dry
-		col = Color{159, 0, 0, 181} // dark red
+		return Color{159, 0, 0, 181} // dark red
 	}
+}
 
+// function to draw weather background and label on the canvas, can be called inside the function DrawToCanvas in drawing.go
+func DrawWeatherBackground(c *canvas.Canvas, weather string, config Config) {
+	col := weatherColor(weather)
 	c.SetFillColor(canvas.MakeColor(col.R, col.G, col.B))
 	c.ClearRect(0, 0, config.CanvasWidth, config.CanvasWidth)
 	c.Fill()
@@ -92,3 +108,10 @@ func DrawWeatherBackground(c *canvas.Canvas, weather string, config Config) {
 	// c.SetFont("Arial", 20)
 	// c.FillText(10, 25, weather)
 }
+
+// function to draw the weather as a band across the top of the canvas, for when a terrain map covers the background
+func DrawWeatherLabel(c *canvas.Canvas, weather string, config Config) {
+	col := weatherColor(weather)
+	c.SetFillColor(canvas.MakeColor(col.R, col.G, col.B))
+	c.ClearRect(0, 0, config.CanvasWidth, max(2, config.CanvasWidth/50))
+}
@@ -1,7 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
 	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 
 	"programingProject_main/canvas"
@@ -25,8 +34,9 @@ func TestUpdatePropulsionDirection(t *testing.T) {
 		{MovementSpeed: OrderedPair{10, 10}, PropulsionDirection: OrderedPair{0, -1}},
 	}
 
+	rng, _ := newRandomSource(1)
 	for i, f := range tests {
-		newDir := UpdatePropulsionDirection(f)
+		newDir := UpdatePropulsionDirection(f, rng)
 		if math.Hypot(newDir.x, newDir.y) == 0 {
 			t.Fatalf("case %d: propulsion direction should not be zero", i)
 		}
@@ -119,7 +129,8 @@ func TestCountSpecies(t *testing.T) {
 }
 
 func TestInitFamilies(t *testing.T) {
-	families := InitFamilies("rabbit", 100, 5, 500)
+	rng, _ := newRandomSource(1)
+	families := InitFamilies("rabbit", 100, 5, 500, rng)
 
 	if len(families) != initial_family_number {
 		t.Fatalf("wrong number of families, got %d", len(families))
@@ -128,12 +139,24 @@ func TestInitFamilies(t *testing.T) {
 	sum := 0
 	for _, f := range families {
 		sum += f.Size
+		if f.species.Name != "rabbit" || math.Abs(NormOrdered(f.MovementSpeed)-5) > 1e-9 {
+			t.Fatalf("family should be rabbits moving at speed 5, got %s at %g", f.species.Name, NormOrdered(f.MovementSpeed))
+		}
 	}
 	if sum != 100 {
 		t.Fatalf("population mismatch, got %d", sum)
 	}
 }
 
+func TestInitializeFamiliesWithoutDryLand(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	sea := Polygon{Points: []OrderedPair{{-1, -1}, {cfg.Width + 1, -1}, {cfg.Width + 1, cfg.Width + 1}, {-1, cfg.Width + 1}}}
+	rng, _ := newRandomSource(1)
+	if families := initializeFamilies(&cfg, sea, rng); len(families) != 0 {
+		t.Fatalf("no family should start in a world of water, got %d", len(families))
+	}
+}
+
 func TestConsumePlants(t *testing.T) {
 	eco := Ecosystem{
 		Families: []Family{
@@ -165,15 +188,85 @@ func TestPlantGrowth(t *testing.T) {
 		{size: 5},
 	}
 
-	newPlants := PlantGrowth(plants, 1.0)
+	before := append([]Plant(nil), plants...)
+	rng, _ := newRandomSource(1)
+	newPlants := PlantGrowth(plants, NewDefaultPlantConfig(), 1.0, rng)
 
 	for i, p := range newPlants {
-		if p.size < plants[i].size {
+		if p.size < before[i].size {
 			t.Fatalf("plant %d should not shrink", i)
 		}
 	}
 }
 
+func TestPlantGrowthIsLogistic(t *testing.T) {
+	cfg := NewDefaultPlantConfig()
+	cfg.RegrowthChance = 0
+	rng, _ := newRandomSource(2)
+	plants := []Plant{{size: 1, maxSize: 20}, {size: 19, maxSize: 20}, {size: 1}}
+	for step := 0; step < 2000; step++ {
+		plants = PlantGrowth(plants, cfg, 1.0, rng)
+		for i, p := range plants {
+			capacity := p.maxSize
+			if capacity == 0 {
+				capacity = cfg.MaxSize
+			}
+			if p.size > capacity+1e-9 {
+				t.Fatalf("step %d: plant %d grew past its maximum: %g > %g", step, i, p.size, capacity)
+			}
+		}
+	}
+	if math.Abs(plants[0].size-20) > 0.01 || math.Abs(plants[2].size-cfg.MaxSize) > 0.01 {
+		t.Fatalf("plants should settle at their maximum, got %g and %g", plants[0].size, plants[2].size)
+	}
+
+	// Frozen weather lowers the maximum, so a full plant dies back.
+	frozen := 1.0 + CoefficientOfPlantIncrease("Frozen")
+	before := plants[0].size
+	plants = PlantGrowth(plants, cfg, frozen, rng)
+	if plants[0].size >= before {
+		t.Fatalf("a full plant should shrink in Frozen weather, %g -> %g", before, plants[0].size)
+	}
+}
+
+func TestPlantDiesWithoutCapacity(t *testing.T) {
+	cfg := NewDefaultPlantConfig()
+	cfg.RegrowthChance = 0
+	rng, _ := newRandomSource(2)
+	plants := []Plant{{size: 15, maxSize: 20}, {size: 0.5}}
+	plants = PlantGrowth(plants, cfg, 0, rng)
+	for i, p := range plants {
+		if p.size != 0 {
+			t.Fatalf("plant %d should die where the maximum is zero, got size %g", i, p.size)
+		}
+	}
+}
+
+func TestDeadPlantsRegrowFromSeed(t *testing.T) {
+	cfg := NewDefaultPlantConfig()
+	rng, _ := newRandomSource(3)
+
+	cfg.RegrowthChance = 0
+	plants := PlantGrowth([]Plant{{size: 0}}, cfg, 1.0, rng)
+	if plants[0].size != 0 {
+		t.Fatalf("plant regrew with a regrowth chance of 0")
+	}
+	cfg.RegrowthChance = 1
+	plants = PlantGrowth(plants, cfg, 1.0, rng)
+	if plants[0].size != cfg.SeedSize {
+		t.Fatalf("dead plant should regrow with the seed size %g, got %g", cfg.SeedSize, plants[0].size)
+	}
+
+	// Over a whole run the plant mass stays below the sum of the maxima.
+	eco := BuildEcosystemFromConfig(NewDefaultEcosystemConfig())
+	if err := RunSimulation(&eco, 300, 0.1); err != nil {
+		t.Fatal(err)
+	}
+	if limit := float64(len(eco.Plants)) * eco.settings().Plants.MaxSize * (1 + CoefficientOfPlantIncrease("Rainy")); CountPlantMass(&eco) > limit {
+		t.Fatalf("plant mass %g exceeds %g", CountPlantMass(&eco), limit)
+	}
+}
+
 func TestLakeFunctions_IsInLake(t *testing.T) {
 	l := InitializeLake(0, 0, 10)
 
@@ -370,6 +463,26 @@ func TestDrawWeatherBackground(t *testing.T) {
 	}
 }
 
+func TestWeatherLabelIsDrawnOverTerrain(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Terrain.Map = []string{"r"} // rock everywhere
+	eco := BuildEcosystemFromConfig(cfg)
+	eco.weather = "Frozen"
+	eco.Families = nil
+
+	img := DrawToCanvas(eco, Config{CanvasWidth: 100})
+	same := func(x, y int, want Color) bool {
+		r, g, b, _ := img.At(x, y).RGBA()
+		return uint8(r>>8) == want.R && uint8(g>>8) == want.G && uint8(b>>8) == want.B
+	}
+	if !same(5, 0, weatherColor("Frozen")) || !same(95, 1, weatherColor("Frozen")) {
+		t.Fatalf("the weather label should be drawn over the terrain, got %v", img.At(5, 0))
+	}
+	if !same(5, 50, terrainColors[Rock]) {
+		t.Fatalf("the terrain should cover the rest of the background, got %v", img.At(5, 50))
+	}
+}
+
 /* ================================
    Tests for functionsA_W.go
 ================================ */
@@ -422,8 +535,9 @@ func TestRandomPartition(t *testing.T) {
 		{8, 4, 2},  // exact fit
 	}
 
+	rng, _ := newRandomSource(1)
 	for i, tt := range tests {
-		parts := randomPartition(tt.total, tt.k, tt.min)
+		parts := randomPartition(tt.total, tt.k, tt.min, rng)
 
 		sum := 0
 		for _, p := range parts {
@@ -485,8 +599,9 @@ func TestUpdatePopulationsBasicGrowth(t *testing.T) {
 	}
 
 	// Case 3: predator-prey interaction within Eating_Threshold
-	preySpecies := Species{GrowthRate: 0.30, ContactGrowthRate: -0.10, Type: "prey"}
-	predSpecies := Species{GrowthRate: -0.10, ContactGrowthRate: 0.20, Type: "predator"}
+	// Named after species the default interaction matrix lets meet.
+	preySpecies := Species{Name: "rabbit", GrowthRate: 0.30, ContactGrowthRate: -0.10, Type: "prey"}
+	predSpecies := Species{Name: "wolf", GrowthRate: -0.10, ContactGrowthRate: 0.20, Type: "predator"}
 
 	eco3 := Ecosystem{
 		Families: []Family{
@@ -716,14 +831,2272 @@ func TestCheck(t *testing.T) {
 	}
 
 	for i, tt := range tests {
-		gotA, gotB := Check(tt.A, tt.B)
+		gotA, gotB := Check(tt.A, tt.B, Eating_Threshold, defaultEcosystemConfig.Interactions, Ecosystem_Width, BoundaryTorus)
 		if !almostEqual(gotA, tt.expA, 1e-6) || !almostEqual(gotB, tt.expB, 1e-6) {
 			t.Fatalf("case %d (%s): expected (%f,%f), got (%f,%f)",
 				i, tt.name, tt.expA, tt.expB, gotA, gotB)
 		}
 	}
+
+	// Across the edge of the world the two only meet on a torus.
+	A := Family{Position: OrderedPair{0, Ecosystem_Width - 2}, species: wolf}
+	B := Family{Position: OrderedPair{0, 2}, species: rabbit}
+	if gotA, _ := Check(A, B, Eating_Threshold, defaultEcosystemConfig.Interactions, Ecosystem_Width, BoundaryTorus); gotA != wolf.ContactGrowthRate {
+		t.Fatalf("torus: the wolf should reach the rabbit across the edge, got %f", gotA)
+	}
+	if gotA, _ := Check(A, B, Eating_Threshold, defaultEcosystemConfig.Interactions, Ecosystem_Width, BoundaryReflect); gotA != 0 {
+		t.Fatalf("reflect: the wall separates the wolf and the rabbit, got %f", gotA)
+	}
 }
 
 func TestDemoSimulationRun(t *testing.T) {
 	DemoSimulationRun()
 }
+
+/* ================================
+   Tests for scenario.go
+================================ */
+
+func TestParseScenarioJSON(t *testing.T) {
+	data := []byte(`{
+		"width": 300,
+		"species": {
+			"rabbit": {"type": "prey", "growth_rate": 0.1, "contact_growth_rate": -0.2},
+			"fox":    {"type": "predator", "growth_rate": -0.02, "contact_growth_rate": 0.2}
+		},
+		"population": {
+			"initial_populations": {"rabbit": 40, "fox": 10},
+			"carrying_capacities": {"rabbit": 400},
+			"eating_threshold": 8
+		},
+		"lake": {"radius": 30, "max_radius": 40, "center": {"x": 150, "y": 120}},
+		"plants": {"count": 50, "growth_coefficient": 0.02}
+	}`)
+
+	cfg, err := ParseScenario(data, "json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Width != 300 {
+		t.Fatalf("width not loaded, got %g", cfg.Width)
+	}
+	if len(cfg.Species) != 2 || cfg.Species["fox"].Name != "fox" {
+		t.Fatalf("species not loaded or name not filled in: %+v", cfg.Species)
+	}
+	if cfg.Population.InitialPopulations["fox"] != 10 || len(cfg.Population.InitialPopulations) != 2 {
+		t.Fatalf("initial populations should replace defaults, got %v", cfg.Population.InitialPopulations)
+	}
+	if cfg.Population.EatingThreshold != 8 {
+		t.Fatalf("eating threshold not loaded, got %g", cfg.Population.EatingThreshold)
+	}
+	if cfg.Lake.Center != (OrderedPair{150, 120}) || cfg.Lake.MaxRadius != 40 {
+		t.Fatalf("lake not loaded: %+v", cfg.Lake)
+	}
+	if cfg.Plants.Count != 50 || cfg.Plants.GrowthCoefficient != 0.02 {
+		t.Fatalf("plants not loaded: %+v", cfg.Plants)
+	}
+	// Fields missing from the file keep their defaults.
+	if cfg.Movement.MaxSpeed != Max_Family_Speed || cfg.Plants.ConsumptionRate != consumptionRate {
+		t.Fatalf("defaults not kept: %+v %+v", cfg.Movement, cfg.Plants)
+	}
+}
+
+func TestParseScenarioYAMLMatchesJSON(t *testing.T) {
+	yamlData := []byte("width: 250\nweather:\n  initial_weather: Rainy\n  step_interval: 10\n")
+	jsonData := []byte(`{"width": 250, "weather": {"initial_weather": "Rainy", "step_interval": 10}}`)
+
+	fromYAML, err := ParseScenario(yamlData, "yaml")
+	if err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	fromJSON, err := ParseScenario(jsonData, "json")
+	if err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if fromYAML.Width != fromJSON.Width || fromYAML.Weather != fromJSON.Weather {
+		t.Fatalf("yaml and json differ: %+v vs %+v", fromYAML.Weather, fromJSON.Weather)
+	}
+	// The lake was not given, so it keeps the default centre.
+	if fromYAML.Lake.Center != NewDefaultLakeConfig().Center {
+		t.Fatalf("lake default not kept: %+v", fromYAML.Lake)
+	}
+}
+
+func TestParseScenarioErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		format  string
+		wantErr string
+	}{
+		{"unknown field", `{"widht": 10}`, "json", `unknown field "widht"`},
+		{"bad format", `{}`, "toml", "unsupported scenario format"},
+		{"undefined species", `{"population": {"initial_populations": {"bear": 3}}}`, "json", `species "bear" is not defined`},
+		{"bad species type", `{"species": {"bear": {"type": "fungus"}}, "population": {"initial_populations": {}, "carrying_capacities": {}}}`, "json", "species.bear.type"},
+		{"negative width", `{"width": -1}`, "json", "width: must be positive"},
+		{"family sizes", `{"population": {"min_family_size": 10, "max_family_size": 10}}`, "json", "population.max_family_size"},
+		{"lake outside", "lake:\n  center: {x: 900, y: 10}\n", "yaml", "lake.center"},
+		{"weather", `{"weather": {"initial_weather": "Snowy"}}`, "json", "weather.initial_weather"},
+		{"population model", `{"dynamics": {"model": "logistic"}}`, "json", "dynamics.model"},
+		{"negative prey plant bonus", `{"dynamics": {"prey_plant_bonus": -0.1}}`, "json", "dynamics.prey_plant_bonus"},
+		{"river without width", `{"waters": [{"shape": "river", "points": [{"x": 0, "y": 0}, {"x": 10, "y": 0}]}]}`, "json", "waters[0].width"},
+		{"unknown water shape", `{"waters": [{"shape": "sea"}]}`, "json", "waters[0].shape"},
+		{"no dry land", `{"waters": [{"shape": "polygon", "points": [{"x": -1, "y": -1}, {"x": 9999, "y": -1}, {"x": 9999, "y": 9999}, {"x": -1, "y": 9999}]}]}`, "json", "waters: leave no dry land"},
+		{"ragged terrain map", `{"terrain": {"map": ["gg", "g"]}}`, "json", "terrain.map"},
+		{"impassable terrain", `{"terrain": {"rock": {"speed": 0}}}`, "json", "terrain.rock.speed"},
+		{"kill energy above max", `{"energy": {"max": 10, "kill_energy": 40}}`, "json", "energy.kill_energy"},
+		{"dehydration threshold above max", `{"hydration": {"max": 5, "dehydration_threshold": 6}}`, "json", "hydration.dehydration_threshold"},
+		{"unknown boundary", `{"movement": {"boundary": "sphere"}}`, "json", "movement.boundary"},
+		{"human planting chance", `{"humans": {"planting_chance": 2}}`, "json", "humans.planting_chance"},
+		{"interaction with unknown species", `{"interactions": {"wolf": {"bear": {"predation": 0.1}}}}`, "json", "interactions.wolf.bear"},
+		{"invalid yaml", "width: [1, 2", "yaml", "invalid YAML"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseScenario([]byte(tt.data), tt.format)
+		if err == nil {
+			t.Fatalf("%s: expected an error", tt.name)
+		}
+		if !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("%s: expected error containing %q, got %q", tt.name, tt.wantErr, err.Error())
+		}
+	}
+}
+
+func TestLoadScenarioFiles(t *testing.T) {
+	for _, path := range []string{"scenarios/default.json", "scenarios/deer_and_wolves.yaml", "scenarios/river_valley.yaml"} {
+		if _, err := LoadScenario(path); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	}
+
+	// Saving and loading the defaults must round-trip.
+	path := filepath.Join(t.TempDir(), "roundtrip.json")
+	if err := SaveScenario(path, NewDefaultEcosystemConfig()); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	loaded, err := LoadScenario(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, NewDefaultEcosystemConfig()) {
+		t.Fatalf("round trip changed the configuration")
+	}
+}
+
+/* ================================
+   Tests for simulation_tools.go
+================================ */
+
+func TestBuildEcosystemFromConfig(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Width = 200
+	cfg.Species = map[string]Species{
+		"rabbit": SpeciesRegistry["rabbit"],
+		"wolf":   SpeciesRegistry["wolf"],
+	}
+	cfg.Population.InitialPopulations = map[string]int{"rabbit": 60, "wolf": 12}
+	cfg.Population.CarryingCapacities = map[string]int{"rabbit": 90}
+	cfg.Population.FamiliesPerSpecies = 4
+	cfg.Population.MinFamilySize = 3
+	cfg.Lake = LakeConfig{Radius: 20, MaxRadius: 25, Center: OrderedPair{50, 60}}
+	cfg.Weather.InitialWeather = "Rainy"
+	cfg.Plants.Count = 30
+	cfg.Plants.MinInitialSize = 1
+	cfg.Plants.MaxInitialSize = 2
+
+	eco := BuildEcosystemFromConfig(cfg)
+
+	counts := CountSpecies(&eco)
+	if counts["rabbit"] != 60 || counts["wolf"] != 12 || len(counts) != 2 {
+		t.Fatalf("initial populations not honored: %v", counts)
+	}
+	if len(eco.Families) != 8 {
+		t.Fatalf("expected 4 families per species, got %d families", len(eco.Families))
+	}
+	for i, f := range eco.Families {
+		if f.Size < 3 {
+			t.Fatalf("family %d smaller than min family size: %d", i, f.Size)
+		}
+		if f.Position.x < 0 || f.Position.x >= 200 || f.Position.y < 0 || f.Position.y >= 200 {
+			t.Fatalf("family %d outside the configured width: %+v", i, f.Position)
+		}
+		if math.Abs(NormOrdered(f.MovementSpeed)-cfg.Movement.InitialSpeed) > 1e-9 {
+			t.Fatalf("family %d initial speed not honored", i)
+		}
+	}
+	for i, p := range eco.Plants {
+		if p.size < 1 || p.size > 2 {
+			t.Fatalf("plant %d size %f outside configured range", i, p.size)
+		}
+	}
+	if len(eco.Plants) > 30 {
+		t.Fatalf("more plants than configured: %d", len(eco.Plants))
+	}
+	if eco.width != 200 || eco.weather != "Rainy" || eco.Lake.MaxRadius != 25 || eco.Lake.Position != (OrderedPair{50, 60}) {
+		t.Fatalf("world settings not honored: width=%g weather=%s lake=%+v", eco.width, eco.weather, eco.Lake)
+	}
+	if len(eco.CarryingCapacity) != 1 || eco.CarryingCapacity["rabbit"] != 90 {
+		t.Fatalf("carrying capacities not honored: %v", eco.CarryingCapacity)
+	}
+
+	// The ecosystem keeps its own copy of the config.
+	cfg.Population.InitialPopulations["rabbit"] = 1
+	if eco.settings().Population.InitialPopulations["rabbit"] != 60 {
+		t.Fatalf("ecosystem config should not alias the caller's maps")
+	}
+}
+
+func TestUpdateEcosystemUsesConfig(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Weather.Enabled = false
+	cfg.Weather.InitialWeather = "Rainy"
+	cfg.Movement.MaxSpeed = 2
+	cfg.Population.MaxFamilySize = math.MaxInt // no splits, so no family gets the split speed boost
+	eco := BuildEcosystemFromConfig(cfg)
+
+	for step := 0; step < 150; step++ {
+		UpdateEcosystem(&eco, 1.0)
+		if eco.weather != "Rainy" {
+			t.Fatalf("step %d: weather changed although it is disabled", step)
+		}
+		for i, f := range eco.Families {
+			// Rainy weather does not change speed, so the configured cap applies as is.
+			if NormOrdered(f.MovementSpeed) > cfg.Movement.MaxSpeed+1e-9 {
+				t.Fatalf("step %d: family %d faster than max speed: %f", step, i, NormOrdered(f.MovementSpeed))
+			}
+		}
+	}
+
+	// SplitLargeFamilies reads the maximum family size from the config.
+	small := NewDefaultEcosystemConfig()
+	small.Population.MaxFamilySize = 30
+	split := Ecosystem{Families: []Family{{Size: 50}, {Size: 20}}, config: &small}
+	SplitLargeFamilies(&split)
+	if len(split.Families) != 3 {
+		t.Fatalf("expected the family of 50 to split, got %d families", len(split.Families))
+	}
+}
+
+func TestExampleConfigVariantsDiffer(t *testing.T) {
+	base := NewDefaultEcosystemConfig()
+	variants := ExampleConfigVariants(base)
+	if len(variants) != 3 {
+		t.Fatalf("expected 3 variants, got %d", len(variants))
+	}
+
+	lake := BuildEcosystemFromConfig(variants[0])
+	if lake.Lake.MaxRadius <= base.Lake.MaxRadius {
+		t.Fatalf("larger lake variant not applied")
+	}
+	fast := BuildEcosystemFromConfig(variants[1])
+	if fast.settings().Movement.MaxSpeed <= base.Movement.MaxSpeed {
+		t.Fatalf("faster movement variant not applied")
+	}
+	if fast.settings().Movement.TimeStep >= base.Movement.TimeStep {
+		t.Fatalf("faster movement variant should take smaller time steps, got %g", fast.settings().Movement.TimeStep)
+	}
+	predators := BuildEcosystemFromConfig(variants[2])
+	if predators.CarryingCapacity["wolf"] <= base.Population.CarryingCapacities["wolf"] {
+		t.Fatalf("predator capacity variant not applied")
+	}
+	if base.Population.CarryingCapacities["wolf"] != defaultCarryingCapacity["wolf"] {
+		t.Fatalf("variants must not modify the base config")
+	}
+}
+
+/* ================================
+   Tests for random.go
+================================ */
+
+func TestSeededRunsAreIdentical(t *testing.T) {
+	runCSV := func(seed int64) string {
+		cfg := NewDefaultEcosystemConfig()
+		cfg.Seed = seed
+		timePoints := SimulateEcosystem(BuildEcosystemFromConfig(cfg), 200, 0.1)
+		var buf strings.Builder
+		if err := WritePopulationCSV(&buf, timePoints, 1); err != nil {
+			t.Fatalf("write csv: %v", err)
+		}
+		return buf.String()
+	}
+
+	first := runCSV(42)
+	second := runCSV(42)
+	if first != second {
+		t.Fatalf("two runs with the same seed produced different population logs")
+	}
+	if runCSV(43) == first {
+		t.Fatalf("runs with different seeds should differ")
+	}
+	if !strings.HasPrefix(first, "Generation,rabbit,sheep,deer,wolf,human,plant_mass,rabbit_juvenile,") {
+		t.Fatalf("unexpected csv header: %q", strings.SplitN(first, "\n", 2)[0])
+	}
+}
+
+func TestBuildEcosystemRecordsSeed(t *testing.T) {
+	eco := BuildEcosystemFromConfig(NewDefaultEcosystemConfig())
+	if eco.settings().Seed == 0 {
+		t.Fatalf("a seed should be picked and recorded when none is given")
+	}
+
+	// An ecosystem without a config still gets a random stream.
+	e := Ecosystem{}
+	if e.random() == nil || e.random() != e.random() {
+		t.Fatalf("random stream should be created once and reused")
+	}
+}
+
+/* ================================
+   Tests for cli.go
+================================ */
+
+func TestRunCLIExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	badScenario := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(badScenario, []byte(`{"width": -1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"no command", nil, exitUsage},
+		{"unknown command", []string{"fly"}, exitUsage},
+		{"help", []string{"help"}, exitOK},
+		{"command help", []string{"run", "--help"}, exitOK},
+		{"unknown flag", []string{"run", "--bogus"}, exitUsage},
+		{"bad number", []string{"run", "--steps", "ten"}, exitUsage},
+		{"zero steps", []string{"run", "--steps", "0"}, exitUsage},
+		{"negative dt", []string{"render", "--dt", "-1"}, exitUsage},
+		{"negative workers", []string{"run", "--workers", "-2"}, exitUsage},
+		{"unknown boundary", []string{"sweep", "--boundary", "sphere"}, exitUsage},
+		{"stray argument", []string{"run", "100"}, exitUsage},
+		{"bad frame frequency", []string{"render", "--frame-every", "0"}, exitUsage},
+		{"missing scenario", []string{"run", "--config", filepath.Join(dir, "missing.json")}, exitError},
+		{"invalid scenario", []string{"run", "--config", badScenario}, exitError},
+		{"validate nothing", []string{"validate"}, exitUsage},
+		{"validate invalid", []string{"validate", badScenario}, exitError},
+		{"validate ok", []string{"validate", "scenarios/default.json", "scenarios/deer_and_wolves.yaml"}, exitOK},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		if got := runCLI(tt.args, &stdout, &stderr); got != tt.want {
+			t.Fatalf("%s: expected exit code %d, got %d (stderr: %s)", tt.name, tt.want, got, stderr.String())
+		}
+	}
+}
+
+func TestRunCLIRunAndSweep(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "log.csv")
+	var stdout, stderr strings.Builder
+	code := runCLI([]string{"run", "--steps", "20", "--seed", "5", "--log-every", "5", "--quiet", "--out", logPath}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("run failed with %d: %s", code, stderr.String())
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	// header + generations 0, 5, 10, 15, 20
+	if len(lines) != 6 || !strings.HasPrefix(lines[5], "20,") {
+		t.Fatalf("unexpected log contents:\n%s", data)
+	}
+
+	sweepPath := filepath.Join(dir, "sweep.csv")
+	code = runCLI([]string{"sweep", "--steps", "5", "--seeds", "2", "--out", sweepPath}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("sweep failed with %d: %s", code, stderr.String())
+	}
+	data, err = os.ReadFile(sweepPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header + (base + 3 variants) * 2 seeds
+	if n := len(strings.Split(strings.TrimSpace(string(data)), "\n")); n != 9 {
+		t.Fatalf("expected 9 sweep lines, got %d", n)
+	}
+}
+
+/* ================================
+   Tests for engine.go
+================================ */
+
+func TestRunSimulationStreamsEveryStep(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Seed = 11
+	eco := BuildEcosystemFromConfig(cfg)
+
+	var steps []int
+	counter := ObserverFunc(func(step int, e *Ecosystem) error {
+		if e != &eco {
+			t.Fatalf("observer should see the live ecosystem")
+		}
+		steps = append(steps, step)
+		return nil
+	})
+	metrics := NewMetricsObserver(10)
+	var buf strings.Builder
+	csvObserver := NewCSVObserver(&buf, 25)
+	frames := NewFrameObserver(Config{CanvasWidth: 50}, 50)
+
+	if err := RunSimulation(&eco, 100, 0.1, counter, metrics, csvObserver, frames); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(steps) != 101 || steps[0] != 0 || steps[100] != 100 {
+		t.Fatalf("observer should see steps 0..100, got %d calls", len(steps))
+	}
+	if metrics.Series.Length() != 11 || metrics.Series.Last().Step != 100 {
+		t.Fatalf("metrics observer recorded %d snapshots", metrics.Series.Length())
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 6 || lines[0] != strings.Join(PopulationLogHeader(&cfg), ",") {
+		t.Fatalf("unexpected csv output:\n%s", buf.String())
+	}
+	if len(frames.Frames()) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(frames.Frames()))
+	}
+}
+
+func TestRunSimulationStopsOnObserverError(t *testing.T) {
+	eco := BuildEcosystemFromConfig(NewDefaultEcosystemConfig())
+	calls := 0
+	failing := ObserverFunc(func(step int, e *Ecosystem) error {
+		calls++
+		if step == 3 {
+			return errors.New("disk full")
+		}
+		return nil
+	})
+	err := RunSimulation(&eco, 10, 0.1, failing)
+	if err == nil || !strings.Contains(err.Error(), "step 3: disk full") {
+		t.Fatalf("expected the observer error, got %v", err)
+	}
+	if calls != 4 {
+		t.Fatalf("run should stop at the failing step, got %d calls", calls)
+	}
+}
+
+func TestRunSimulationMatchesStepByStepUpdates(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Seed = 99
+
+	streamed := BuildEcosystemFromConfig(cfg)
+	var buf strings.Builder
+	if err := RunSimulation(&streamed, 50, 0.1, NewCSVObserver(&buf, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	manual := BuildEcosystemFromConfig(cfg)
+	want := []string{strings.Join(PopulationLogHeader(&cfg), ","), strings.Join(PopulationLogRow(0, &manual), ",")}
+	for step := 1; step <= 50; step++ {
+		UpdateEcosystem(&manual, 0.1)
+		want = append(want, strings.Join(PopulationLogRow(step, &manual), ","))
+	}
+	if strings.TrimSpace(buf.String()) != strings.Join(want, "\n") {
+		t.Fatalf("streamed log differs from the step-by-step log")
+	}
+}
+
+/* ================================
+   Tests for state.go
+================================ */
+
+func TestCloneIsIndependent(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Seed = 5
+	eco := BuildEcosystemFromConfig(cfg)
+	snapshot := eco.Clone()
+	reference := eco.Clone()
+
+	for step := 0; step < 30; step++ {
+		UpdateEcosystem(&eco, 0.1)
+	}
+	eco.CarryingCapacity["wolf"] = 1
+
+	if !reflect.DeepEqual(snapshot, reference) {
+		t.Fatalf("updating the original changed a cloned snapshot")
+	}
+
+	// The clone continues the same random stream, so it replays the same run.
+	for step := 0; step < 30; step++ {
+		UpdateEcosystem(&snapshot, 0.1)
+	}
+	snapshot.CarryingCapacity["wolf"] = 1
+	if !reflect.DeepEqual(snapshot.Families, eco.Families) || !reflect.DeepEqual(snapshot.Plants, eco.Plants) {
+		t.Fatalf("clone did not continue the same run")
+	}
+}
+
+func TestSimulateEcosystemFramesStayImmutable(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Seed = 8
+	timePoints := SimulateEcosystem(BuildEcosystemFromConfig(cfg), 40, 0.1)
+
+	recorded := make([]Ecosystem, len(timePoints))
+	for i := range timePoints {
+		recorded[i] = timePoints[i].Clone()
+	}
+
+	// Keep updating the last frame; no earlier frame may change.
+	last := &timePoints[len(timePoints)-1]
+	for step := 0; step < 20; step++ {
+		UpdateEcosystem(last, 0.1)
+	}
+	for i := 0; i < len(timePoints)-1; i++ {
+		if !reflect.DeepEqual(timePoints[i].Families, recorded[i].Families) || !reflect.DeepEqual(timePoints[i].Plants, recorded[i].Plants) {
+			t.Fatalf("frame %d changed after later updates", i)
+		}
+	}
+
+	// Plants grow every step, so consecutive frames must not share plant data.
+	if CountPlantMass(&timePoints[0]) == CountPlantMass(&timePoints[len(timePoints)-2]) {
+		t.Fatalf("plant mass is identical in the first and last frame; frames share plants")
+	}
+
+	// The recorded history matches a streamed run with the same seed.
+	var streamed, history strings.Builder
+	eco := BuildEcosystemFromConfig(cfg)
+	if err := RunSimulation(&eco, 39, 0.1, NewCSVObserver(&streamed, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := WritePopulationCSV(&history, recorded, 1); err != nil {
+		t.Fatal(err)
+	}
+	if streamed.String() != history.String() {
+		t.Fatalf("history differs from the streamed run")
+	}
+}
+
+/* ================================
+   Tests for checkpoint.go
+================================ */
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Seed = 21
+	eco := BuildEcosystemFromConfig(cfg)
+	if err := RunSimulation(&eco, 15, 0.1); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf strings.Builder
+	if err := WriteCheckpoint(&buf, &eco); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ReadCheckpoint(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.step != 15 || loaded.weather != eco.weather || loaded.weatherChangeCounter != eco.weatherChangeCounter {
+		t.Fatalf("step/weather not restored: got step %d weather %q", loaded.step, loaded.weather)
+	}
+	if !reflect.DeepEqual(loaded.Families, eco.Families) || !reflect.DeepEqual(loaded.Plants, eco.Plants) {
+		t.Fatalf("families or plants differ after the round trip")
+	}
+	if eco.settlement == nil || !reflect.DeepEqual(loaded.settlement, eco.settlement) {
+		t.Fatalf("settlement not restored: got %v, want %v", loaded.settlement, eco.settlement)
+	}
+	if loaded.random().Uint64() != eco.random().Uint64() {
+		t.Fatalf("random stream not restored")
+	}
+}
+
+func TestCheckpointRejectsOtherVersions(t *testing.T) {
+	eco := BuildEcosystemFromConfig(NewDefaultEcosystemConfig())
+	var buf strings.Builder
+	if err := WriteCheckpoint(&buf, &eco); err != nil {
+		t.Fatal(err)
+	}
+	data := strings.Replace(buf.String(), fmt.Sprintf(`"version":%d`, checkpointVersion), `"version":99`, 1)
+	if _, err := ReadCheckpoint(strings.NewReader(data)); err == nil || !strings.Contains(err.Error(), "version 99") {
+		t.Fatalf("expected a version error, got %v", err)
+	}
+}
+
+func TestResumeMatchesUninterruptedRun(t *testing.T) {
+	dir := t.TempDir()
+	fullLog := filepath.Join(dir, "full.csv")
+	splitLog := filepath.Join(dir, "split.csv")
+	checkpoint := filepath.Join(dir, "checkpoint.json")
+	var stdout, stderr strings.Builder
+
+	code := runCLI([]string{"run", "--steps", "60", "--seed", "9", "--quiet", "--out", fullLog}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("run failed with %d: %s", code, stderr.String())
+	}
+	code = runCLI([]string{"run", "--steps", "30", "--seed", "9", "--quiet", "--out", splitLog, "--checkpoint", checkpoint}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("run with checkpoint failed with %d: %s", code, stderr.String())
+	}
+	code = runCLI([]string{"resume", "--steps", "30", "--quiet", "--out", splitLog, "--checkpoint", checkpoint}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("resume failed with %d: %s", code, stderr.String())
+	}
+
+	full, err := os.ReadFile(fullLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	split, err := os.ReadFile(splitLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(full) != string(split) {
+		t.Fatalf("resumed log differs from the uninterrupted run:\n%s\n---\n%s", full, split)
+	}
+
+	eco, err := LoadCheckpoint(checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eco.step != 60 {
+		t.Fatalf("checkpoint should be at step 60 after resuming, got %d", eco.step)
+	}
+
+	if code := runCLI([]string{"resume", "--checkpoint", filepath.Join(dir, "missing.json")}, &stdout, &stderr); code != exitError {
+		t.Fatalf("resume of a missing checkpoint returned %d", code)
+	}
+	if code := runCLI([]string{"run", "--checkpoint-every", "5"}, &stdout, &stderr); code != exitUsage {
+		t.Fatalf("--checkpoint-every without --checkpoint returned %d", code)
+	}
+}
+
+/* ================================
+   Tests for spatial.go
+================================ */
+
+func TestSpatialGridFindsWrappedNeighbours(t *testing.T) {
+	rng, _ := newRandomSource(4)
+	width := 100.0
+	positions := make([]OrderedPair, 400)
+	for i := range positions {
+		positions[i] = OrderedPair{x: rng.Float64() * width, y: rng.Float64() * width}
+	}
+	// Points just across the edges of the world, and outside it.
+	positions = append(positions, OrderedPair{x: 0.5, y: 50}, OrderedPair{x: 99.5, y: 50}, OrderedPair{x: -3, y: 104}, OrderedPair{x: math.NaN(), y: 1})
+
+	wrapped := func(a, b float64) float64 {
+		d := math.Abs(math.Mod(a-b, width))
+		return math.Min(d, width-d)
+	}
+	for _, radius := range []float64{0, 2.5, 7, 15, 60} {
+		grid := newSpatialGrid(positions, width, radius)
+		for i, p := range positions {
+			got := grid.near(p, radius, nil)
+			for j, q := range positions {
+				if math.Hypot(wrapped(p.x, q.x), wrapped(p.y, q.y)) <= radius && !slices.Contains(got, j) {
+					t.Fatalf("radius %g: point %d at %v misses neighbour %d at %v", radius, i, p, j, q)
+				}
+			}
+		}
+	}
+}
+
+func TestSeparationForceSameWithGrid(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Seed = 12
+	eco := BuildEcosystemFromConfig(cfg)
+	want := make([]OrderedPair, len(eco.Families))
+	for i := range eco.Families {
+		fx, fy := CalculateSeparationForce(&eco, i)
+		want[i] = OrderedPair{x: fx, y: fy}
+	}
+	eco.indexFamilies(cfg.Movement.SeparationThreshold)
+	for i := range eco.Families {
+		fx, fy := CalculateSeparationForce(&eco, i)
+		if math.Abs(fx-want[i].x) > 1e-12 || math.Abs(fy-want[i].y) > 1e-12 {
+			t.Fatalf("family %d: force with grid (%g, %g), without (%g, %g)", i, fx, fy, want[i].x, want[i].y)
+		}
+	}
+}
+
+/* ================================
+   Tests for parallel.go
+================================ */
+
+func TestParallelRunsAreReproducible(t *testing.T) {
+	run := func(workers int) string {
+		cfg := NewDefaultEcosystemConfig()
+		cfg.Seed = 17
+		cfg.Workers = workers
+		eco := BuildEcosystemFromConfig(cfg)
+		var log strings.Builder
+		if err := RunSimulation(&eco, 80, 0.1, NewCSVObserver(&log, 1)); err != nil {
+			t.Fatal(err)
+		}
+		return log.String()
+	}
+	for _, workers := range []int{2, 4, 7} {
+		if run(workers) != run(workers) {
+			t.Fatalf("two runs with %d workers differ", workers)
+		}
+	}
+}
+
+func TestParallelChunksCoverEveryIndex(t *testing.T) {
+	for _, workers := range []int{1, 3, 8} {
+		for _, n := range []int{0, 1, 5, 100} {
+			seen := make([]int, n)
+			chunks := make([]int, workers)
+			parallelChunks(workers, n, func(worker, lo, hi int) {
+				chunks[worker]++
+				for i := lo; i < hi; i++ {
+					seen[i]++
+				}
+			})
+			for i, c := range seen {
+				if c != 1 {
+					t.Fatalf("workers=%d n=%d: index %d visited %d times", workers, n, i, c)
+				}
+			}
+			if workers > 1 && slices.Contains(chunks, 0) {
+				t.Fatalf("workers=%d n=%d: a worker was not started: %v", workers, n, chunks)
+			}
+		}
+	}
+}
+
+func TestConsumePlantsSameForAnyWorkerCount(t *testing.T) {
+	consume := func(workers int) ([]Plant, map[int]float64) {
+		cfg := NewDefaultEcosystemConfig()
+		cfg.Seed = 3
+		cfg.Workers = workers
+		cfg.Plants.Count = 2000
+		eco := BuildEcosystemFromConfig(cfg)
+		consumed := ConsumePlants(&eco, 0.5, 40)
+		return eco.Plants, consumed
+	}
+	wantPlants, wantConsumed := consume(1)
+	for _, workers := range []int{2, 5} {
+		plants, consumed := consume(workers)
+		if !reflect.DeepEqual(plants, wantPlants) {
+			t.Fatalf("%d workers left different plants than 1 worker", workers)
+		}
+		if len(consumed) != len(wantConsumed) {
+			t.Fatalf("%d workers: %d grazing families, want %d", workers, len(consumed), len(wantConsumed))
+		}
+		for fi, mass := range wantConsumed {
+			if math.Abs(consumed[fi]-mass) > 1e-9 {
+				t.Fatalf("%d workers: family %d ate %g, want %g", workers, fi, consumed[fi], mass)
+			}
+		}
+	}
+}
+
+/* ================================
+   Tests for steering.go
+================================ */
+
+func TestPursuitForce(t *testing.T) {
+	wolf := SpeciesRegistry["wolf"]
+	rabbit := SpeciesRegistry["rabbit"]
+	eco := Ecosystem{
+		width: 500,
+		Families: []Family{
+			{Size: 10, Position: OrderedPair{100, 100}, species: wolf},
+			{Size: 10, Position: OrderedPair{100, 160}, species: rabbit}, // 60 away
+			{Size: 10, Position: OrderedPair{130, 100}, species: rabbit}, // 30 away, the nearest
+			{Size: 10, Position: OrderedPair{105, 100}, species: wolf},   // other predators are ignored
+		},
+	}
+	got := PursuitForce(&eco, 0)
+	if math.Abs(got.x-1) > 1e-12 || math.Abs(got.y) > 1e-12 {
+		t.Fatalf("wolf should head for the nearest rabbit at +x, got %v", got)
+	}
+	if got := PursuitForce(&eco, 1); got != (OrderedPair{}) {
+		t.Fatalf("prey must not pursue, got %v", got)
+	}
+
+	// Out of sight: nothing to chase.
+	eco.Families[1].Position = OrderedPair{400, 400}
+	eco.Families[2].Position = OrderedPair{100, 100 + wolf.PerceptionRadius + 1}
+	if got := PursuitForce(&eco, 0); got != (OrderedPair{}) {
+		t.Fatalf("prey beyond the perception radius was chased: %v", got)
+	}
+}
+
+func TestEvasionForce(t *testing.T) {
+	wolf := SpeciesRegistry["wolf"]
+	deer := SpeciesRegistry["deer"]
+	eco := Ecosystem{
+		width: 500,
+		Families: []Family{
+			{Size: 10, Position: OrderedPair{200, 200}, species: deer},
+			{Size: 10, Position: OrderedPair{210, 200}, species: wolf}, // close, on the right
+			{Size: 10, Position: OrderedPair{200, 250}, species: wolf}, // far, above
+		},
+	}
+	got := EvasionForce(&eco, 0)
+	if got.x >= 0 || got.y >= 0 {
+		t.Fatalf("deer should flee left and down, got %v", got)
+	}
+	if math.Abs(got.x) <= math.Abs(got.y) {
+		t.Fatalf("the closer wolf should dominate, got %v", got)
+	}
+	if NormOrdered(got) > 1+1e-12 {
+		t.Fatalf("evasion force stronger than 1: %v", got)
+	}
+	if got := EvasionForce(&eco, 1); got != (OrderedPair{}) {
+		t.Fatalf("predators must not evade, got %v", got)
+	}
+
+	// Turning the weights off leaves only wandering and separation.
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Movement.PursuitWeight = 0
+	cfg.Movement.EvasionWeight = 0
+	eco.config = &cfg
+	rngA, _ := newRandomSource(1)
+	rngB, _ := newRandomSource(1)
+	withoutSteering := UpdateAcceleration(&eco, 0, rngA)
+	cfg.Movement.EvasionWeight = 3
+	withSteering := UpdateAcceleration(&eco, 0, rngB)
+	if d := SubOrdered(withSteering, withoutSteering); math.Abs(d.x-3*got.x) > 1e-9 || math.Abs(d.y-3*got.y) > 1e-9 {
+		t.Fatalf("evasion weight not applied: difference %v, force %v", d, got)
+	}
+}
+
+func TestFlockingForces(t *testing.T) {
+	sheep := SpeciesRegistry["sheep"]
+	wolf := SpeciesRegistry["wolf"]
+	eco := Ecosystem{
+		width: 500,
+		Families: []Family{
+			{Size: 10, Position: OrderedPair{100, 100}, MovementSpeed: OrderedPair{0, 0}, species: sheep},
+			{Size: 10, Position: OrderedPair{120, 100}, MovementSpeed: OrderedPair{10, 0}, species: sheep},
+			{Size: 10, Position: OrderedPair{120, 120}, MovementSpeed: OrderedPair{10, 10}, species: sheep},
+			{Size: 10, Position: OrderedPair{80, 100}, MovementSpeed: OrderedPair{-40, 0}, species: wolf},   // other species are ignored
+			{Size: 10, Position: OrderedPair{400, 400}, MovementSpeed: OrderedPair{0, -40}, species: sheep}, // out of sight
+		},
+	}
+
+	// The neighbours' centre is (120, 110), 20 right and 10 up of the family.
+	cohesion := CohesionForce(&eco, 0)
+	want := OrderedPair{x: 20 / sheep.PerceptionRadius, y: 10 / sheep.PerceptionRadius}
+	if math.Abs(cohesion.x-want.x) > 1e-12 || math.Abs(cohesion.y-want.y) > 1e-12 {
+		t.Fatalf("cohesion = %v, want %v", cohesion, want)
+	}
+
+	// The neighbours' average velocity is (10, 5).
+	alignment := AlignmentForce(&eco, 0)
+	maxSpeed := defaultEcosystemConfig.Movement.MaxSpeed
+	want = OrderedPair{x: 10 / maxSpeed, y: 5 / maxSpeed}
+	if math.Abs(alignment.x-want.x) > 1e-12 || math.Abs(alignment.y-want.y) > 1e-12 {
+		t.Fatalf("alignment = %v, want %v", alignment, want)
+	}
+
+	// A family with no same-species neighbours feels nothing.
+	if got := CohesionForce(&eco, 4); got != (OrderedPair{}) {
+		t.Fatalf("lonely family got cohesion %v", got)
+	}
+	if got := AlignmentForce(&eco, 3); got != (OrderedPair{}) {
+		t.Fatalf("lonely wolf got alignment %v", got)
+	}
+}
+
+/* ================================
+   Tests for foraging.go
+================================ */
+
+func TestForagingForce(t *testing.T) {
+	rabbit := SpeciesRegistry["rabbit"]
+	eco := Ecosystem{
+		width: 500,
+		Families: []Family{
+			{Size: 10, Position: OrderedPair{100, 100}, species: rabbit},
+			{Size: 10, Position: OrderedPair{100, 100}, species: SpeciesRegistry["wolf"]},
+		},
+		Plants: []Plant{
+			{position: OrderedPair{120, 100}, size: 3},  // the rich patch, 20 to the right
+			{position: OrderedPair{80, 100}, size: 1},   // a poor patch, 20 to the left
+			{position: OrderedPair{100, 300}, size: 50}, // out of range
+			{position: OrderedPair{100, 90}, size: 0},   // eaten
+		},
+	}
+	radius := defaultEcosystemConfig.Foraging.Radius
+
+	// Mass-weighted centre: (3*20 - 1*20) / 4 = 10 to the right.
+	got := ForagingForce(&eco, 0)
+	if math.Abs(got.x-10/radius) > 1e-12 || math.Abs(got.y) > 1e-12 {
+		t.Fatalf("foraging force = %v, want (%g, 0)", got, 10/radius)
+	}
+	if got := ForagingForce(&eco, 1); got != (OrderedPair{}) {
+		t.Fatalf("predators must not forage, got %v", got)
+	}
+
+	eco.Families[0].energy = defaultEcosystemConfig.Energy.Max
+	fed := ForagingWeight(&eco, 0)
+	eco.Families[0].energy = 0
+	if starving := ForagingWeight(&eco, 0); starving <= fed {
+		t.Fatalf("a starving family should forage harder: fed %g, starving %g", fed, starving)
+	}
+}
+
+func TestUpdateHunger(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Energy.Enabled = false
+	cfg.Foraging.HungerRate = 1
+	cfg.Foraging.Satiety = 2
+	eco := Ecosystem{
+		config: &cfg,
+		Families: []Family{
+			{Size: 10, species: SpeciesRegistry["sheep"], hunger: 0.5},
+			{Size: 10, species: SpeciesRegistry["sheep"], hunger: 0.5},
+			{Size: 10, species: SpeciesRegistry["wolf"]},
+		},
+	}
+	UpdateHunger(&eco, map[int]float64{1: 0.1}, 0.1)
+	if got := eco.Families[0].hunger; math.Abs(got-0.6) > 1e-12 {
+		t.Fatalf("hungry family: got %g, want 0.6", got)
+	}
+	if got := eco.Families[1].hunger; math.Abs(got-0.4) > 1e-12 {
+		t.Fatalf("family that ate 0.1: got %g, want 0.4", got)
+	}
+	if got := eco.Families[2].hunger; got != 0 {
+		t.Fatalf("predators do not graze, so they do not get hungry here, got %g", got)
+	}
+
+	// Hunger stays between 0 and 1, and the foraging pull follows it.
+	for i := 0; i < 20; i++ {
+		UpdateHunger(&eco, map[int]float64{1: 10}, 0.1)
+	}
+	if eco.Families[0].hunger != 1 || eco.Families[1].hunger != 0 {
+		t.Fatalf("hunger not clamped: %g, %g", eco.Families[0].hunger, eco.Families[1].hunger)
+	}
+	if got, want := ForagingWeight(&eco, 0), cfg.Foraging.Weight+cfg.Foraging.HungerWeight; got != want {
+		t.Fatalf("a starving family without an energy reserve should forage with weight %g, got %g", want, got)
+	}
+
+	// With an energy reserve hunger follows the reserve instead.
+	cfg.Energy.Enabled = true
+	eco.Families[0].energy = cfg.Energy.Max
+	UpdateHunger(&eco, nil, 0.1)
+	if eco.Families[0].hunger != 1 || ForagingWeight(&eco, 0) != cfg.Foraging.Weight {
+		t.Fatalf("a family with a full energy reserve should forage as a fed one, weight %g", ForagingWeight(&eco, 0))
+	}
+}
+
+/* ================================
+   Tests for energy.go
+================================ */
+
+func TestUpdateEnergy(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	e := cfg.Energy
+	eco := Ecosystem{
+		config: &cfg,
+		Families: []Family{
+			{Size: 10, species: SpeciesRegistry["sheep"], energy: 5},                                    // standing, hungry
+			{Size: 10, species: SpeciesRegistry["sheep"], energy: 5},                                    // standing, grazing
+			{Size: 10, species: SpeciesRegistry["wolf"], energy: 5, MovementSpeed: OrderedPair{40, 0}},  // running, no kill
+			{Size: 10, species: SpeciesRegistry["wolf"], energy: 5, MovementSpeed: OrderedPair{40, 0}},  // running, kill
+			{Size: 2, species: SpeciesRegistry["human"], energy: 5, MovementSpeed: OrderedPair{40, 0}},  // no energy model
+			{Size: 100, species: SpeciesRegistry["wolf"], energy: 5, MovementSpeed: OrderedPair{40, 0}}, // big pack, no kill
+		},
+	}
+	UpdateEnergy(&eco, map[int]float64{1: 1}, []float64{0, 0, 0, 6, 0, 0}, 0.1)
+
+	idle := 5 - (e.BaseCost+e.SizeCost*10/float64(cfg.Population.MaxFamilySize))*0.1
+	if got := eco.Families[0].energy; math.Abs(got-idle) > 1e-12 {
+		t.Fatalf("idle family: energy %g, want %g", got, idle)
+	}
+	if got, want := eco.Families[1].energy, idle+e.PlantEnergy/10; math.Abs(got-want) > 1e-12 {
+		t.Fatalf("grazing family: energy %g, want %g", got, want)
+	}
+	if eco.Families[2].energy >= eco.Families[0].energy {
+		t.Fatalf("running should burn more energy than standing: %g vs %g", eco.Families[2].energy, eco.Families[0].energy)
+	}
+	if got, want := eco.Families[3].energy-eco.Families[2].energy, 0.6*e.KillEnergy*0.1; math.Abs(got-want) > 1e-12 {
+		t.Fatalf("a kill should add %g energy, added %g", want, got)
+	}
+	if eco.Families[4].energy != 5 {
+		t.Fatalf("humans do not use the energy model, energy changed to %g", eco.Families[4].energy)
+	}
+	if eco.Families[5].energy >= eco.Families[2].energy {
+		t.Fatalf("a big pack should burn more per wolf: %g vs %g", eco.Families[5].energy, eco.Families[2].energy)
+	}
+}
+
+func TestEnergyDrivesBirthsAndDeaths(t *testing.T) {
+	e := NewDefaultEnergyConfig()
+	if gr := EnergyGrowthRate(Family{energy: 0}, e); gr != -e.StarvationRate {
+		t.Fatalf("empty reserve: growth rate %g, want %g", gr, -e.StarvationRate)
+	}
+	if gr := EnergyGrowthRate(Family{energy: (e.StarvationThreshold + e.ReproductionThreshold) / 2}, e); gr != 0 {
+		t.Fatalf("between the thresholds: growth rate %g, want 0", gr)
+	}
+	if gr := EnergyGrowthRate(Family{energy: e.Max}, e); gr != e.ReproductionRate {
+		t.Fatalf("full reserve: growth rate %g, want %g", gr, e.ReproductionRate)
+	}
+
+	// Wolves that never catch anything die off.
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Seed = 4
+	cfg.Population.InitialPopulations = map[string]int{"wolf": 80}
+	eco := BuildEcosystemFromConfig(cfg)
+	if err := RunSimulation(&eco, 1000, 0.1); err != nil {
+		t.Fatal(err)
+	}
+	if wolves := CountSpecies(&eco)["wolf"]; wolves != 0 {
+		t.Fatalf("%d wolves survived without prey", wolves)
+	}
+}
+
+func TestDefaultEcosystemStaysViable(t *testing.T) {
+	// The energy reserve must not starve the default ecosystem: every species
+	// lives through the first 2000 steps, long after the predators would have
+	// collapsed with too tight an energy budget.
+	for _, seed := range []int64{1, 2, 3, 4, 7} {
+		cfg := NewDefaultEcosystemConfig()
+		cfg.Seed = seed
+		eco := BuildEcosystemFromConfig(cfg)
+		for step := 1; step <= 2000; step++ {
+			UpdateEcosystem(&eco, cfg.Movement.TimeStep)
+			counts := CountSpecies(&eco)
+			for _, name := range sortedKeys(cfg.Population.InitialPopulations) {
+				if counts[name] == 0 {
+					t.Fatalf("seed %d: %s died out at step %d", seed, name, step)
+				}
+			}
+		}
+	}
+}
+
+/* ================================
+   Tests for vegetation.go
+================================ */
+
+func TestFrostKillsPlants(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Vegetation.FrostKillChance = 1
+	cfg.Vegetation.SeedingChance = 0
+	cfg.Plants.RegrowthChance = 0
+	eco := BuildEcosystemFromConfig(cfg)
+	eco.weather = "Sunny"
+	UpdateVegetation(&eco)
+	if CountPlantMass(&eco) == 0 {
+		t.Fatalf("frost killed plants in Sunny weather")
+	}
+	eco.weather = "Frozen"
+	UpdateVegetation(&eco)
+	if mass := CountPlantMass(&eco); mass != 0 {
+		t.Fatalf("every plant should die in Frozen weather with a kill chance of 1, %g left", mass)
+	}
+}
+
+func TestPlantsGrowFasterNearTheLake(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Vegetation.SeedingChance = 0
+	lake := InitializeLake(250, 250, 50)
+	eco := BuildEcosystemFromConfig(cfg)
+	eco.Lake = lake
+	eco.Plants = []Plant{
+		{position: OrderedPair{305, 250}, size: 5, maxSize: 30}, // 5 from the shore
+		{position: OrderedPair{450, 250}, size: 5, maxSize: 30}, // far from the lake
+	}
+	eco.weather = "Sunny"
+	UpdateVegetation(&eco)
+	if eco.Plants[0].size <= eco.Plants[1].size {
+		t.Fatalf("shore plant %g should outgrow the distant plant %g", eco.Plants[0].size, eco.Plants[1].size)
+	}
+	if f := LakeGrowthFactor(OrderedPair{450, 250}, lake, cfg.Vegetation); f != 1 {
+		t.Fatalf("no bonus expected beyond the lake's influence, got %g", f)
+	}
+	if f := LakeGrowthFactor(OrderedPair{300, 250}, lake, cfg.Vegetation); f != 1+cfg.Vegetation.LakeGrowthBonus {
+		t.Fatalf("full bonus expected at the shore, got %g", f)
+	}
+}
+
+func TestSpreadSeeds(t *testing.T) {
+	plantCfg := NewDefaultPlantConfig()
+	cfg := NewDefaultVegetationConfig()
+	cfg.SeedingChance = 1
+	cfg.MaxPlants = 5
+	lake := InitializeLake(0, 0, 0)
+	rng, _ := newRandomSource(6)
+
+	plants := []Plant{
+		{position: OrderedPair{100, 100}, size: 30, maxSize: 30},
+		{position: OrderedPair{300, 300}, size: 2, maxSize: 30}, // too small to seed
+	}
+	for step := 0; step < 50; step++ {
+		plants = SpreadSeeds(plants, 500, BoundaryTorus, lake, plantCfg, cfg, rng)
+		for i := 2; i < len(plants); i++ {
+			plants[i].size = 15 // let the seedlings grow up
+		}
+	}
+	if len(plants) != cfg.MaxPlants {
+		t.Fatalf("plants should spread up to MaxPlants=%d, got %d", cfg.MaxPlants, len(plants))
+	}
+	for i, p := range plants[2:] {
+		if d := distance(p.position, OrderedPair{300, 300}); d < 100 {
+			t.Fatalf("seedling %d grew next to the small plant: %v", i, p.position)
+		}
+	}
+
+	// A seed landing next to a dead plant revives it instead of adding a plant.
+	cfg.MinSpacing = 5
+	cfg.SeedRadius = 5
+	cfg.MaxPlants = 100
+	dead := []Plant{{position: OrderedPair{50, 50}, size: 30, maxSize: 30}}
+	for a := 0; a < 64; a++ {
+		angle := float64(a) / 64 * 2 * math.Pi
+		dead = append(dead, Plant{position: OrderedPair{50 + 5*math.Cos(angle), 50 + 5*math.Sin(angle)}})
+	}
+	dead = SpreadSeeds(dead, 500, BoundaryTorus, lake, plantCfg, cfg, rng)
+	revived := 0
+	for _, p := range dead[1:] {
+		if p.size > 0 {
+			revived++
+		}
+	}
+	if len(dead) != 65 || revived != 1 {
+		t.Fatalf("expected one revived plant and no new one, got %d plants with %d revived", len(dead), revived)
+	}
+}
+
+func TestSeedsRespectTheBoundary(t *testing.T) {
+	plantCfg := NewDefaultPlantConfig()
+	cfg := NewDefaultVegetationConfig()
+	cfg.SeedingChance = 1
+	cfg.MaxPlants = 1000
+	lake := InitializeLake(250, 250, 0)
+
+	for _, boundary := range []string{BoundaryTorus, BoundaryReflect, BoundaryAbsorb} {
+		rng, _ := newRandomSource(8)
+		plants := []Plant{{position: OrderedPair{1, 1}, size: 30, maxSize: 30}}
+		for step := 0; step < 40; step++ {
+			plants = SpreadSeeds(plants, 500, boundary, lake, plantCfg, cfg, rng)
+		}
+		acrossEdge := false
+		for _, p := range plants {
+			if p.position.x < 0 || p.position.x > 500 || p.position.y < 0 || p.position.y > 500 {
+				t.Fatalf("%s: seed took root outside the world at %v", boundary, p.position)
+			}
+			if p.position.x > 250 || p.position.y > 250 {
+				acrossEdge = true
+			}
+		}
+		// Only on a torus do seeds from the corner land on the far side of the world.
+		if acrossEdge != (boundary == BoundaryTorus) {
+			t.Fatalf("%s: seeds across the edge: %v", boundary, acrossEdge)
+		}
+		if len(plants) < 2 {
+			t.Fatalf("%s: the plant in the corner should still spread, got %d plants", boundary, len(plants))
+		}
+	}
+}
+
+/* ================================
+   Tests for lifestages.go
+================================ */
+
+func TestAgeFamilyMovesMembersThroughStages(t *testing.T) {
+	s := Species{Name: "test", Type: "prey", MaturationAge: 1, SenescenceAge: 2, Lifespan: 3, Fecundity: 1}
+	f := Family{Size: 1000, species: s, stages: [numLifeStages]int{Juvenile: 1000}}
+	rng, _ := newRandomSource(5)
+
+	sawAdults, sawSenescent := false, false
+	for step := 0; step < 300 && f.Size > 0; step++ {
+		AgeFamily(&f, 0.1, rng)
+		stages := f.StageCounts()
+		if stages != f.stages {
+			t.Fatalf("step %d: stages %v do not add up to size %d", step, f.stages, f.Size)
+		}
+		sawAdults = sawAdults || stages[Adult] > 0
+		sawSenescent = sawSenescent || stages[Senescent] > 0
+	}
+	if !sawAdults || !sawSenescent {
+		t.Fatalf("juveniles never grew up (adults %v, senescent %v)", sawAdults, sawSenescent)
+	}
+	if f.Size != 0 {
+		t.Fatalf("%d members outlived their lifespan many times over", f.Size)
+	}
+}
+
+func TestOnlyAdultsBreed(t *testing.T) {
+	s := Species{Name: "test", Type: "prey", MaturationAge: 1, SenescenceAge: 2, Lifespan: 3, Fecundity: 2}
+	young := Family{Size: 10, species: s, stages: [numLifeStages]int{Juvenile: 6, Senescent: 4}}
+	if got := young.breedingSize(); got != 0 {
+		t.Fatalf("juveniles and senescent members bred: breeding size %g", got)
+	}
+	adults := Family{Size: 10, species: s, stages: [numLifeStages]int{3, 5, 2}}
+	if got := adults.breedingSize(); got != 10 {
+		t.Fatalf("breeding size %g, want 5 adults times fecundity 2", got)
+	}
+
+	rng, _ := newRandomSource(6)
+	adults.addMembers(4, rng)
+	if adults.Size != 14 || adults.stages[Juvenile] != 7 {
+		t.Fatalf("newborns should be juveniles, got size %d stages %v", adults.Size, adults.stages)
+	}
+	adults.addMembers(-20, rng)
+	if adults.Size != 0 || adults.stages != [numLifeStages]int{} {
+		t.Fatalf("removing more members than there are should empty the family, got size %d stages %v", adults.Size, adults.stages)
+	}
+
+	// Species without a lifespan do not age and breed with every member.
+	plain := Family{Size: 10, species: Species{Name: "plain"}}
+	if got := plain.breedingSize(); got != 10 {
+		t.Fatalf("breeding size without age structure %g, want 10", got)
+	}
+	if got := plain.StageCounts(); got != [numLifeStages]int{Adult: 10} {
+		t.Fatalf("a family without stages should count as adults, got %v", got)
+	}
+}
+
+func TestSplitAndMergeKeepStages(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	s := cfg.Species["deer"]
+	eco := Ecosystem{width: 500, config: &cfg, Families: []Family{
+		{Size: 120, species: s, stages: [numLifeStages]int{31, 60, 29}},
+	}}
+	eco.random()
+	SplitLargeFamilies(&eco)
+	if len(eco.Families) != 2 {
+		t.Fatalf("expected the family to split, got %d families", len(eco.Families))
+	}
+	total := [numLifeStages]int{}
+	for _, f := range eco.Families {
+		if f.StageCounts() != f.stages {
+			t.Fatalf("split family stages %v do not add up to size %d", f.stages, f.Size)
+		}
+		for st, n := range f.stages {
+			total[st] += n
+		}
+	}
+	if total != [numLifeStages]int{31, 60, 29} {
+		t.Fatalf("split lost members: %v", total)
+	}
+
+	small := Family{Size: 2, Position: eco.Families[0].Position, species: s, stages: [numLifeStages]int{2, 0, 0}}
+	eco.Families = []Family{eco.Families[0], small}
+	MergeFamilies(&eco)
+	if len(eco.Families) != 1 || eco.Families[0].stages != [numLifeStages]int{17, 30, 14} {
+		t.Fatalf("merged stages %v, want [17 30 14]", eco.Families[0].stages)
+	}
+}
+
+func TestDeathsHitEveryMemberWhileAdultsBreed(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Energy.Enabled = false
+	cfg.Hydration.Enabled = false
+	s := Species{Name: "test", Type: "prey", MaturationAge: 10, SenescenceAge: 90, Lifespan: 100, Fecundity: 1}
+	eco := Ecosystem{config: &cfg, Families: []Family{
+		{Size: 100, species: s, stages: [numLifeStages]int{Juvenile: 50, Adult: 50}},
+	}}
+
+	// 50 adults give 0.4 births each and 0.1 of all 100 members die: a net gain
+	// of 10, although the net rate of 0.3 is positive.
+	applyGrowthRates(&eco, []FamilyRates{{Growth: 0.4, Deaths: 0.1}}, 1)
+	if got := eco.Families[0].Size; got != 110 {
+		t.Fatalf("family size %d, want 110", got)
+	}
+}
+
+/* ================================
+   Tests for dynamics.go
+================================ */
+
+func TestFunctionalResponse(t *testing.T) {
+	a, h := 0.5, 2.0
+	if got := FunctionalResponse(100, a, h, 1); got != 50 {
+		t.Fatalf("mass action: got %g, want 50", got)
+	}
+	// Type II and III level off at 1/h prey per unit of time.
+	for _, kind := range []int{2, 3} {
+		if got := FunctionalResponse(1e6, a, h, kind); !almostEqual(got, 1/h, 1e-3) {
+			t.Fatalf("type %d: a swamped predator catches %g, want about %g", kind, got, 1/h)
+		}
+	}
+	// At low prey numbers type III catches much less than type II.
+	if low2, low3 := FunctionalResponse(0.5, a, h, 2), FunctionalResponse(0.5, a, h, 3); low3 >= low2 {
+		t.Fatalf("type III (%g) should catch less than type II (%g) when prey are scarce", low3, low2)
+	}
+}
+
+func TestDensityDependence(t *testing.T) {
+	for name, density := range map[string]func(rate, count, capacity float64) float64{
+		"logistic":      logisticGrowth,
+		"beverton-holt": bevertonHoltGrowth,
+	} {
+		if got := density(0.5, 0, 100); !almostEqual(got, 0.5, 1e-12) {
+			t.Fatalf("%s: rate of an empty population %g, want 0.5", name, got)
+		}
+		if got := density(0.5, 100, 100); got != 0 {
+			t.Fatalf("%s: rate at the carrying capacity %g, want 0", name, got)
+		}
+	}
+	if got := exponentialGrowth(0.5, 100, 100); got != 0.5 {
+		t.Fatalf("exponential growth should ignore density, got %g", got)
+	}
+}
+
+func TestPredationModelSharesTheCatch(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Dynamics = DynamicsConfig{Model: "lotka_volterra", ConversionEfficiency: 1}
+	cfg.Interactions = InteractionMatrix{"predator": {"prey": {Predation: 0.01}}}
+	prey := Species{Name: "prey", Type: "prey"}
+	predator := Species{Name: "predator", Type: "predator"}
+	eco := Ecosystem{width: 500, config: &cfg, Families: []Family{
+		{Size: 30, Position: OrderedPair{100, 100}, species: prey},
+		{Size: 10, Position: OrderedPair{105, 100}, species: predator},
+		{Size: 50, Position: OrderedPair{110, 100}, species: prey},
+		{Size: 40, Position: OrderedPair{300, 300}, species: prey},
+	}}
+	step := newPopulationStep(&eco, nil, 1, 0.1)
+	rates := cfg.populationModel().GrowthRates(&eco, step)
+
+	// The predator reaches 80 prey and catches 0.01*80 per member, 8 in all.
+	if rates[1].Deaths != 0 || !almostEqual(rates[1].Growth, 0.8, 1e-12) {
+		t.Fatalf("predator rates %+v, want a growth rate of 0.8", rates[1])
+	}
+	eaten := 0.0
+	for i, f := range eco.Families {
+		if f.species.Type == "prey" {
+			eaten -= (rates[i].Growth - rates[i].Deaths) * float64(f.Size)
+		}
+	}
+	if !almostEqual(eaten, 8, 1e-9) {
+		t.Fatalf("prey lost %g individuals per unit of time, want the 8 the predators caught", eaten)
+	}
+	if rates[3] != (FamilyRates{}) {
+		t.Fatalf("prey out of reach should not be eaten, got rates %+v", rates[3])
+	}
+}
+
+func TestPreyLoseWhatPredatorsCatch(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Dynamics = DynamicsConfig{Model: "lotka_volterra", ConversionEfficiency: 1}
+	cfg.Interactions = InteractionMatrix{"predator": {"prey": {Predation: 0.01}}}
+	cfg.Energy.Enabled = false
+	cfg.Hydration.Enabled = false
+	prey := Species{Name: "prey", Type: "prey", GrowthRate: 0.5, MaturationAge: 10, SenescenceAge: 90, Lifespan: 100, Fecundity: 1}
+	predator := Species{Name: "predator", Type: "predator"}
+	eco := Ecosystem{width: 500, config: &cfg, Families: []Family{
+		{Size: 100, Position: OrderedPair{100, 100}, species: prey, stages: [numLifeStages]int{Juvenile: 50, Adult: 50}},
+		{Size: 10, Position: OrderedPair{105, 100}, species: predator},
+	}}
+	model := cfg.populationModel()
+	step := newPopulationStep(&eco, nil, model.Response(), 1)
+	caught := step.Caught[0] + step.Caught[1]
+	applyGrowthRates(&eco, model.GrowthRates(&eco, step), 1)
+
+	// The prey's net rate is positive, yet it loses exactly the 10 prey caught
+	// on top of the 25 young its 50 adults have.
+	if !almostEqual(caught, 10, 1e-12) {
+		t.Fatalf("predators caught %g prey, want 10", caught)
+	}
+	if lost := 100 + 25 - eco.Families[0].Size; float64(lost) != caught {
+		t.Fatalf("prey lost %d members, want the %g the predators caught", lost, caught)
+	}
+	if eco.Families[1].Size != 20 {
+		t.Fatalf("every prey caught should give a predator, got %d predators", eco.Families[1].Size)
+	}
+}
+
+/* ================================
+   Tests for predation.go
+================================ */
+
+func TestPredationScalesWithPackSize(t *testing.T) {
+	d := DynamicsConfig{HandlingTime: 0.5}
+	wolf, deer := SpeciesRegistry["wolf"], SpeciesRegistry["deer"]
+	interactions := InteractionMatrix{"wolf": {"deer": {Predation: 0.01}}}
+	neighbours := [][]int{{1}, {0}}
+
+	catch := func(wolves int) float64 {
+		families := []Family{{Size: wolves, species: wolf}, {Size: 10000, species: deer}}
+		caught, _ := Predation(families, neighbours, interactions, d.HandlingTime, 2, 0.1)
+		return caught[0]
+	}
+	if pair, pack := catch(2), catch(100); !almostEqual(pack, 50*pair, 1e-9) {
+		t.Fatalf("a pack of 100 caught %g prey, want 50 times the %g a pair caught", pack, pair)
+	}
+	// With 10000 deer in reach each wolf is close to its limit of 1/HandlingTime.
+	if perWolf := catch(2) / 2; perWolf > 1/d.HandlingTime || perWolf < 0.9/d.HandlingTime {
+		t.Fatalf("each wolf caught %g prey, want just under %g", perWolf, 1/d.HandlingTime)
+	}
+}
+
+func TestPredationIsConserved(t *testing.T) {
+	wolf, deer, rabbit := SpeciesRegistry["wolf"], SpeciesRegistry["deer"], SpeciesRegistry["rabbit"]
+	interactions := InteractionMatrix{"wolf": {"deer": {Predation: 0.05}, "rabbit": {Predation: 0.05}}}
+	families := []Family{
+		{Size: 60, species: wolf},
+		{Size: 40, species: deer},
+		{Size: 3, species: rabbit},
+		{Size: 20, species: wolf},
+		{Size: 25, species: deer}, // out of reach of the first pack
+	}
+	neighbours := [][]int{{1, 2, 3}, {0, 2, 3}, {0, 1, 3}, {0, 1, 2, 4}, {3}}
+	timeStep := 0.5
+	caught, lost := Predation(families, neighbours, interactions, 0.1, 2, timeStep)
+
+	sum := 0.0
+	for i := range families {
+		sum += caught[i] - lost[i]
+		if families[i].species.Type == "prey" && caught[i] != 0 {
+			t.Fatalf("prey family %d caught %g", i, caught[i])
+		}
+		if families[i].species.Type == "predator" && lost[i] != 0 {
+			t.Fatalf("predator family %d lost %g", i, lost[i])
+		}
+		if lost[i]*timeStep > float64(families[i].Size)+1e-9 {
+			t.Fatalf("family %d of %d lost %g within one step", i, families[i].Size, lost[i]*timeStep)
+		}
+	}
+	if !almostEqual(sum, 0, 1e-9) {
+		t.Fatalf("prey caught and prey lost should add up to the same, difference %g", sum)
+	}
+	if caught[0] <= 0 || caught[3] <= 0 {
+		t.Fatalf("both packs should catch something, got %v", caught)
+	}
+	// The rabbits are eaten up, so they lose their whole family in one step.
+	if !almostEqual(lost[2]*timeStep, 3, 1e-9) {
+		t.Fatalf("rabbits lost %g within one step, want all 3", lost[2]*timeStep)
+	}
+}
+
+/* ================================
+   Tests for interactions.go
+================================ */
+
+func TestDefaultInteractionsFollowTypes(t *testing.T) {
+	m := DefaultInteractions(SpeciesRegistry)
+	if got := m.Between("wolf", "deer").Predation; got != defaultAttackRate {
+		t.Fatalf("wolves should eat deer at %g, got %g", defaultAttackRate, got)
+	}
+	if got := m.Between("deer", "wolf"); got.Predation != 0 || got.Avoidance != 1 {
+		t.Fatalf("deer should avoid wolves and not eat them, got %+v", got)
+	}
+	if got := m.Between("human", "wolf"); got != (Interaction{}) {
+		t.Fatalf("humans should not interact by default, got %+v", got)
+	}
+}
+
+func TestInteractionMatrixShapesTheFoodWeb(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	// Wolves eat deer but not sheep, bears graze and eat deer, humans hunt wolves.
+	cfg.Species["bear"] = Species{Name: "bear", Type: "omnivore", PerceptionRadius: 80}
+	cfg.Interactions = InteractionMatrix{
+		"wolf":  {"deer": {Predation: 0.01}},
+		"bear":  {"deer": {Predation: 0.02}},
+		"human": {"wolf": {Predation: 0.05}},
+		"sheep": {"wolf": {Avoidance: 2}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	eco := Ecosystem{width: 500, config: &cfg, Families: []Family{
+		{Size: 20, Position: OrderedPair{100, 100}, species: cfg.Species["wolf"]},
+		{Size: 30, Position: OrderedPair{110, 100}, species: cfg.Species["sheep"]}, // nearer than the deer
+		{Size: 30, Position: OrderedPair{100, 70}, species: cfg.Species["deer"]},
+		{Size: 5, Position: OrderedPair{95, 100}, species: cfg.Species["human"]},
+		{Size: 4, Position: OrderedPair{100, 105}, species: cfg.Species["bear"]},
+	}}
+	neighbours := [][]int{{1, 3, 4}, {0, 3, 4}, nil, {0, 1, 4}, {0, 1, 3}}
+	caught, lost := Predation(eco.Families, neighbours, cfg.Interactions, 0.5, 2, 0.1)
+	if lost[1] != 0 {
+		t.Fatalf("wolves ate sheep they do not hunt: %g", lost[1])
+	}
+	if caught[3] <= 0 || lost[0] <= 0 {
+		t.Fatalf("humans should hunt the wolves: caught %g, wolves lost %g", caught[3], lost[0])
+	}
+
+	// The wolf heads for the deer, not the nearer sheep, and the sheep flee the wolf.
+	if got := PursuitForce(&eco, 0); got.y >= 0 || math.Abs(got.x) > 1e-12 {
+		t.Fatalf("the wolf should head for the deer at -y, got %v", got)
+	}
+	if got := EvasionForce(&eco, 1); got.x <= 0 {
+		t.Fatalf("the sheep should flee the wolf toward +x, got %v", got)
+	}
+	if got := EvasionForce(&eco, 2); got != (OrderedPair{}) {
+		t.Fatalf("the deer avoid nothing in this matrix, got %v", got)
+	}
+	if !cfg.Species["bear"].eatsPlants() {
+		t.Fatalf("omnivores should graze")
+	}
+}
+
+func TestCompetitionCountsAgainstCapacity(t *testing.T) {
+	counts := map[string]int{"sheep": 100, "deer": 50, "wolf": 10}
+	m := InteractionMatrix{
+		"sheep": {"deer": {Competition: 0.4}, "wolf": {Avoidance: 1}},
+		"deer":  {"sheep": {Competition: 0.1}},
+	}
+	got := competingCounts(counts, m)
+	want := map[string]float64{"sheep": 120, "deer": 60, "wolf": 10}
+	for name, w := range want {
+		if !almostEqual(got[name], w, 1e-12) {
+			t.Fatalf("%s: competing count %g, want %g", name, got[name], w)
+		}
+	}
+}
+
+/* ================================
+   Tests for humans.go
+================================ */
+
+func TestHumansSettleAndFarm(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Humans.PlantingChance = 1
+	human := cfg.Species["human"]
+	rng, _ := newRandomSource(1)
+	eco := Ecosystem{width: 500, config: &cfg, Lake: Lake{Position: OrderedPair{450, 450}, Radius: 10}, rng: rng,
+		Families: []Family{
+			{Size: 3, Position: OrderedPair{140, 100}, species: human},
+			{Size: 8, Position: OrderedPair{200, 100}, species: human}, // the largest family founds the settlement
+			{Size: 4, Position: OrderedPair{400, 100}, species: human}, // off the farmland
+		},
+		Plants: []Plant{
+			{position: OrderedPair{202, 100}, size: 25, maxSize: 30}, // ripe, in reach of the founders
+			{position: OrderedPair{195, 100}, size: 10, maxSize: 30}, // not ripe yet
+			{position: OrderedPair{405, 100}, size: 25, maxSize: 30}, // ripe, but off the farmland
+		},
+	}
+
+	UpdateHumans(&eco)
+	if eco.settlement == nil || eco.settlement.Position != (OrderedPair{200, 100}) {
+		t.Fatalf("the largest human family should found the settlement, got %v", eco.settlement)
+	}
+	want := cfg.Humans.HarvestRate * 8
+	if !almostEqual(eco.settlement.Harvested, want, 1e-12) || !almostEqual(eco.Plants[0].size, 25-want, 1e-12) {
+		t.Fatalf("harvested %g (plant left at %g), want %g", eco.settlement.Harvested, eco.Plants[0].size, want)
+	}
+	if eco.Plants[1].size != 10 || eco.Plants[2].size != 25 {
+		t.Fatalf("unripe plants and plants off the farmland must not be harvested: %v", eco.Plants)
+	}
+	// The family at (140, 100) planted a crop; the founders stand next to a plant
+	// and the family off the farmland does not farm.
+	if len(eco.Plants) != 4 || eco.Plants[3].position != (OrderedPair{140, 100}) || eco.Plants[3].maxSize != cfg.Humans.CropSize {
+		t.Fatalf("expected one crop at (140, 100), got %v", eco.Plants[3:])
+	}
+}
+
+func TestSettlementForce(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	eco := Ecosystem{width: 500, config: &cfg, settlement: &Settlement{Position: OrderedPair{200, 200}},
+		Families: []Family{
+			{Size: 5, Position: OrderedPair{210, 200}, species: cfg.Species["deer"]},  // inside the settlement
+			{Size: 5, Position: OrderedPair{200, 500}, species: cfg.Species["deer"]},  // far away
+			{Size: 5, Position: OrderedPair{240, 200}, species: cfg.Species["human"]}, // on the farmland
+			{Size: 5, Position: OrderedPair{200, 40}, species: cfg.Species["human"]},  // 80 beyond the farmland
+		},
+	}
+	if got := SettlementForce(&eco, 0); !almostEqual(got.x, 1, 1e-12) || got.y != 0 {
+		t.Fatalf("deer in the settlement should be pushed out at full strength, got %v", got)
+	}
+	if got := SettlementForce(&eco, 1); got != (OrderedPair{}) {
+		t.Fatalf("deer out of sight of the settlement should not care, got %v", got)
+	}
+	if got := SettlementForce(&eco, 2); got != (OrderedPair{}) {
+		t.Fatalf("humans on the farmland should not be pulled home, got %v", got)
+	}
+	if got := SettlementForce(&eco, 3); got.x != 0 || !almostEqual(got.y, 1, 1e-12) {
+		t.Fatalf("humans far from home should head back at full strength, got %v", got)
+	}
+	if SettlementWeight(&eco, 0) != cfg.Humans.SettlementAvoidance || SettlementWeight(&eco, 3) != cfg.Humans.HomeWeight {
+		t.Fatalf("wrong settlement weights")
+	}
+}
+
+func TestHumansHuntWithoutLivingOffIt(t *testing.T) {
+	m := DefaultInteractions(SpeciesRegistry)
+	if m.Between("human", "deer").Predation <= 0 || m.Between("wolf", "human").Avoidance <= 0 {
+		t.Fatalf("by default humans hunt prey and the other species avoid them: %+v", m["human"])
+	}
+	human := Family{Size: 10, species: SpeciesRegistry["human"]}
+	if got := predationRate(human, 5, 1, defaultEcosystemConfig.Dynamics); got.Growth != 0 || !almostEqual(got.Deaths, 0.1, 1e-12) {
+		t.Fatalf("humans should only lose members, got rates %+v", got)
+	}
+	if usesEnergy(human, defaultEcosystemConfig.Energy) {
+		t.Fatalf("humans do not use the energy model")
+	}
+}
+
+/* ================================
+   Tests for terrain.go
+================================ */
+
+func TestReadTerrainCSV(t *testing.T) {
+	rows, err := ReadTerrainCSV(strings.NewReader("grassland,Forest,2\n m ,g,f\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gfr", "mgf"}; !reflect.DeepEqual(rows, want) {
+		t.Fatalf("got %q, want %q", rows, want)
+	}
+	if _, err := ReadTerrainCSV(strings.NewReader("g,lava\n")); err == nil || !strings.Contains(err.Error(), "row 1, column 2") {
+		t.Fatalf("expected an unknown terrain error, got %v", err)
+	}
+	if _, err := ReadTerrainCSV(strings.NewReader("g,g\ng,4\n")); err == nil {
+		t.Fatalf("terrain number 4 should be rejected")
+	}
+}
+
+func TestTerrainPNGRoundTrip(t *testing.T) {
+	rows := []string{"gfrm", "mmgg", "rfgf"}
+	var buf bytes.Buffer
+	if err := WriteTerrainPNG(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadTerrainPNG(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Fatalf("got %q, want %q", got, rows)
+	}
+	// Colours that are off by a bit still map to the nearest terrain.
+	if got := nearestTerrain(color.RGBA{R: 40, G: 100, B: 50, A: 255}); got != Forest {
+		t.Fatalf("dark green should be forest, got %v", got)
+	}
+}
+
+func TestTerrainAt(t *testing.T) {
+	cfg := NewDefaultTerrainConfig()
+	if got := cfg.At(OrderedPair{10, 10}, 100); got != Grassland {
+		t.Fatalf("without a map everything is grassland, got %v", got)
+	}
+	cfg.Map = []string{"gf", "rm"}
+	tests := []struct {
+		p    OrderedPair
+		want TerrainType
+	}{
+		{OrderedPair{10, 10}, Grassland},
+		{OrderedPair{60, 10}, Forest},
+		{OrderedPair{10, 60}, Rock},
+		{OrderedPair{99.9, 99.9}, Marsh},
+		{OrderedPair{100, 60}, Rock},   // wraps to x = 0
+		{OrderedPair{-10, -10}, Marsh}, // wraps to (90, 90)
+	}
+	for _, tt := range tests {
+		if got := cfg.At(tt.p, 100); got != tt.want {
+			t.Fatalf("At(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestTerrainHidesPreyAndSlowsPlants(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Terrain.Map = []string{"gf"} // the east half of the world is forest
+	cfg.Vegetation.SeedingChance = 0
+	cfg.Vegetation.LakeInfluence = 0
+	eco := Ecosystem{width: 500, config: &cfg, weather: "Sunny", Lake: Lake{Position: OrderedPair{-1000, -1000}},
+		Families: []Family{
+			{Size: 10, Position: OrderedPair{240, 100}, species: cfg.Species["wolf"]},
+			{Size: 10, Position: OrderedPair{290, 100}, species: cfg.Species["deer"]}, // in the forest, 50 away
+			{Size: 10, Position: OrderedPair{240, 170}, species: cfg.Species["deer"]}, // on grassland, 70 away
+		},
+		Plants: []Plant{
+			{position: OrderedPair{100, 100}, size: 10},
+			{position: OrderedPair{400, 100}, size: 10},
+		},
+	}
+	// The wolf sees 80 on grassland but only 40 into the forest, so it goes for the farther deer.
+	if got := PursuitForce(&eco, 0); got.x != 0 || got.y <= 0 {
+		t.Fatalf("the wolf should chase the deer on the grassland, got %v", got)
+	}
+
+	UpdateVegetation(&eco)
+	grass, forest := eco.Plants[0].size-10, eco.Plants[1].size-10
+	if !almostEqual(forest, grass*cfg.Terrain.Forest.Growth, 1e-9) {
+		t.Fatalf("plants in the forest should grow %g times as fast: grew %g vs %g", cfg.Terrain.Forest.Growth, forest, grass)
+	}
+}
+
+func TestLoadScenarioReadsTerrainFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "map.csv"), []byte("g,f\nr,m\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "scenario.json")
+	if err := os.WriteFile(path, []byte(`{"terrain": {"file": "map.csv"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadScenario(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gf", "rm"}; !reflect.DeepEqual(cfg.Terrain.Map, want) {
+		t.Fatalf("got map %q, want %q", cfg.Terrain.Map, want)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"terrain": {"file": "missing.png"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadScenario(path); err == nil {
+		t.Fatalf("a missing terrain file should be an error")
+	}
+}
+
+/* ================================
+   Tests for water.go
+================================ */
+
+func TestPolygonWater(t *testing.T) {
+	square := Polygon{Points: []OrderedPair{{100, 100}, {200, 100}, {200, 200}, {100, 200}}}
+	if !square.Contains(OrderedPair{150, 150}) || square.Contains(OrderedPair{250, 150}) || square.Contains(OrderedPair{150, 99}) {
+		t.Fatalf("wrong point-in-polygon results")
+	}
+	point, normal := square.Shore(OrderedPair{190, 150})
+	if point != (OrderedPair{200, 150}) || normal != (OrderedPair{1, 0}) {
+		t.Fatalf("inside near the east edge: shore %v normal %v", point, normal)
+	}
+	point, normal = square.Shore(OrderedPair{150, 80})
+	if point != (OrderedPair{150, 100}) || normal != (OrderedPair{0, -1}) {
+		t.Fatalf("outside the north edge: shore %v normal %v", point, normal)
+	}
+	if got := PushOutOfWater(OrderedPair{110, 150}, square); got != (OrderedPair{100 - shoreBuffer, 150}) {
+		t.Fatalf("should be pushed out over the west edge, got %v", got)
+	}
+}
+
+func TestRiverWater(t *testing.T) {
+	river := River{Points: []OrderedPair{{0, 100}, {100, 100}, {100, 200}}, Width: 10}
+	if !river.Contains(OrderedPair{50, 104}) || river.Contains(OrderedPair{50, 106}) || !river.Contains(OrderedPair{97, 150}) {
+		t.Fatalf("wrong point-in-river results")
+	}
+	if got := PushOutOfWater(OrderedPair{50, 98}, river); !almostEqual(got.x, 50, 1e-12) || !almostEqual(got.y, 95-shoreBuffer, 1e-12) {
+		t.Fatalf("should be pushed to the north bank, got %v", got)
+	}
+	// A point right on the course is pushed out sideways.
+	if got := PushOutOfWater(OrderedPair{100, 150}, river); river.Contains(got) || !almostEqual(got.y, 150, 1e-12) {
+		t.Fatalf("should be pushed out across the river, got %v", got)
+	}
+	if d := ShoreDistance(OrderedPair{50, 125}, river); !almostEqual(d, 20, 1e-12) {
+		t.Fatalf("shore distance %g, want 20", d)
+	}
+}
+
+func TestWatersCollide(t *testing.T) {
+	// A pond overlapping the lake: pushed out of the lake, a point can land in the pond.
+	water := Waters{
+		Lake{Position: OrderedPair{100, 100}, Radius: 50},
+		Lake{Position: OrderedPair{160, 100}, Radius: 20},
+	}
+	if got := PushOutOfWater(OrderedPair{140, 100}, water); water.Contains(got) {
+		t.Fatalf("still in the water at %v", got)
+	}
+	if d := ShoreDistance(OrderedPair{100, 300}, water); !almostEqual(d, 150, 1e-12) {
+		t.Fatalf("shore distance %g, want 150", d)
+	}
+	if d := ShoreDistance(OrderedPair{100, 300}, Waters{}); !math.IsInf(d, 1) {
+		t.Fatalf("no water should be infinitely far, got %g", d)
+	}
+
+	// A velocity into the shore is reflected, one along it is kept.
+	if got := BounceOffShore(OrderedPair{3, -4}, OrderedPair{0, 1}); got != (OrderedPair{3, 4}) {
+		t.Fatalf("got %v, want (3, 4)", got)
+	}
+	if got := BounceOffShore(OrderedPair{3, 0}, OrderedPair{0, 1}); got != (OrderedPair{3, 0}) {
+		t.Fatalf("got %v, want (3, 0)", got)
+	}
+}
+
+func TestFamiliesStayOutOfConfiguredWater(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Seed = 4
+	cfg.Waters = []WaterConfig{
+		{Shape: "river", Points: []OrderedPair{{0, 60}, {500, 60}}, Width: 30},
+		{Shape: "polygon", Points: []OrderedPair{{350, 350}, {450, 350}, {450, 450}, {350, 450}}},
+		{Shape: "circle", Center: OrderedPair{100, 400}, Radius: 40},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	eco := BuildEcosystemFromConfig(cfg)
+	water := eco.water()
+	for _, p := range eco.Plants {
+		if water.Contains(p.position) {
+			t.Fatalf("plant started in the water at %v", p.position)
+		}
+	}
+	for step := 0; step < 50; step++ {
+		UpdateEcosystem(&eco, cfg.Movement.TimeStep)
+		// Pushed out at the start of every step, no family stays in the water.
+		water = eco.water()
+		for i := range eco.Families {
+			eco.Families[i].Position = PushOutOfWater(eco.Families[i].Position, water)
+			if water.Contains(eco.Families[i].Position) {
+				t.Fatalf("step %d: family stuck in the water at %v", step, eco.Families[i].Position)
+			}
+		}
+	}
+}
+
+func TestPushFamiliesAshoreKeepsThemInTheWorld(t *testing.T) {
+	for _, tt := range []struct {
+		boundary string
+		want     float64 // x of the family after the push, or -1 when it left the world
+	}{
+		{BoundaryTorus, 500 + 2 - 20 - shoreBuffer},
+		{BoundaryReflect, 20 + shoreBuffer - 2},
+		{BoundaryAbsorb, -1},
+	} {
+		cfg := NewDefaultEcosystemConfig()
+		cfg.Movement.Boundary = tt.boundary
+		// The lake reaches over the west edge; a family west of its centre is pushed over the edge.
+		eco := Ecosystem{
+			config:   &cfg,
+			width:    500,
+			Lake:     InitializeLake(2, 250, 20),
+			Families: []Family{{Size: 10, species: SpeciesRegistry["deer"], Position: OrderedPair{1, 250}}},
+		}
+		PushFamiliesAshore(&eco)
+		if tt.want < 0 {
+			if len(eco.Families) != 0 {
+				t.Fatalf("%s: a family pushed over the edge should leave the world, got %v", tt.boundary, eco.Families[0].Position)
+			}
+			continue
+		}
+		if len(eco.Families) != 1 || !almostEqual(eco.Families[0].Position.x, tt.want, 1e-9) {
+			t.Fatalf("%s: got %v, want x = %g", tt.boundary, eco.Families, tt.want)
+		}
+	}
+}
+
+func TestWaterWrapsAcrossTheEdge(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Waters = []WaterConfig{{Shape: "river", Points: []OrderedPair{{100, 0}, {400, 0}}, Width: 40}}
+	// The lake sticks out over the west edge, the river over the north edge.
+	eco := Ecosystem{
+		config:   &cfg,
+		width:    500,
+		Lake:     InitializeLake(5, 250, 20),
+		Families: []Family{{Size: 10, species: SpeciesRegistry["deer"], Position: OrderedPair{495, 250}}},
+	}
+	water := eco.water()
+	if !water.Contains(OrderedPair{495, 250}) || !water.Contains(OrderedPair{250, 490}) {
+		t.Fatalf("on a torus the water should reach across the edges")
+	}
+	PushFamiliesAshore(&eco)
+	if got := eco.Families[0].Position; !almostEqual(got.x, 500+5-20-shoreBuffer, 1e-9) || got.y != 250 {
+		t.Fatalf("a family across the edge from the lake should be pushed out of it, got %v", got)
+	}
+
+	cfg.Movement.Boundary = BoundaryReflect
+	if water := eco.water(); water.Contains(OrderedPair{495, 250}) || water.Contains(OrderedPair{250, 490}) {
+		t.Fatalf("behind walls the water should not reach across the edges")
+	}
+
+	// Families spawned on a torus keep off the water across the edges too.
+	cfg.Movement.Boundary = BoundaryTorus
+	cfg.Seed = 6
+	cfg.Lake.Center, cfg.Lake.Radius, cfg.Lake.MaxRadius = OrderedPair{5, 250}, 60, 60
+	built := BuildEcosystemFromConfig(cfg)
+	water = built.water()
+	for _, f := range built.Families {
+		if water.Contains(f.Position) {
+			t.Fatalf("family started in the water at %v", f.Position)
+		}
+	}
+	for _, p := range built.Plants {
+		if water.Contains(p.position) {
+			t.Fatalf("plant started in the water at %v", p.position)
+		}
+	}
+}
+
+/* ================================
+   Tests for hydration.go
+================================ */
+
+func TestUpdateHydration(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	h := cfg.Hydration
+	eco := Ecosystem{
+		config:  &cfg,
+		weather: "Dry",
+		Lake:    InitializeLake(250, 250, 50),
+		Families: []Family{
+			{Size: 10, species: SpeciesRegistry["sheep"], hydration: 5, Position: OrderedPair{450, 250}}, // far from the water
+			{Size: 10, species: SpeciesRegistry["sheep"], hydration: 5, Position: OrderedPair{305, 250}}, // at the shore
+			{Size: 2, species: SpeciesRegistry["human"], hydration: 5, Position: OrderedPair{450, 250}},  // no hydration reserve
+			{Size: 10, species: SpeciesRegistry["wolf"], hydration: 9.9, Position: OrderedPair{301, 250}},
+		},
+	}
+	UpdateHydration(&eco, 0.1)
+
+	dryLoss := WaterLoss(h, "Dry")
+	if dryLoss <= WaterLoss(h, "Sunny") || WaterLoss(h, "Sunny") <= WaterLoss(h, "Rainy") {
+		t.Fatalf("families should lose water fastest in Dry weather, then Sunny, then Rainy")
+	}
+	if got, want := eco.Families[0].hydration, 5-dryLoss*0.1; math.Abs(got-want) > 1e-12 {
+		t.Fatalf("family far from the water: hydration %g, want %g", got, want)
+	}
+	if got, want := eco.Families[1].hydration, 5+(h.DrinkRate-dryLoss)*0.1; math.Abs(got-want) > 1e-12 {
+		t.Fatalf("family at the shore: hydration %g, want %g", got, want)
+	}
+	if eco.Families[2].hydration != 5 {
+		t.Fatalf("humans have no hydration reserve, hydration changed to %g", eco.Families[2].hydration)
+	}
+	if eco.Families[3].hydration != h.Max {
+		t.Fatalf("hydration should be capped at %g, got %g", h.Max, eco.Families[3].hydration)
+	}
+}
+
+func TestThirstKillsAndLeadsToWater(t *testing.T) {
+	h := NewDefaultHydrationConfig()
+	if gr := HydrationGrowthRate(Family{hydration: 0}, h); gr != -h.DehydrationRate {
+		t.Fatalf("empty reserve: growth rate %g, want %g", gr, -h.DehydrationRate)
+	}
+	if gr := HydrationGrowthRate(Family{hydration: h.DehydrationThreshold}, h); gr != 0 {
+		t.Fatalf("at the threshold: growth rate %g, want 0", gr)
+	}
+
+	cfg := NewDefaultEcosystemConfig()
+	eco := Ecosystem{
+		config:           &cfg,
+		weather:          "Sunny",
+		width:            500,
+		Lake:             InitializeLake(250, 250, 50),
+		CarryingCapacity: map[string]int{},
+		Families: []Family{
+			{Size: 10, species: SpeciesRegistry["deer"], hydration: 0, energy: 5, Position: OrderedPair{400, 250}},
+			{Size: 10, species: SpeciesRegistry["deer"], hydration: h.Max, energy: 5, Position: OrderedPair{250, 400}},
+			{Size: 10, species: SpeciesRegistry["deer"], hydration: 0, energy: 5, Position: OrderedPair{250, 305}},
+		},
+	}
+	if got := WaterForce(&eco, 0); !almostEqual(got.x, -1, 1e-12) || !almostEqual(got.y, 0, 1e-12) {
+		t.Fatalf("a thirsty family east of the lake should head west, got %v", got)
+	}
+	if w := WaterWeight(&eco, 0); w != h.ThirstWeight {
+		t.Fatalf("parched family: weight %g, want %g", w, h.ThirstWeight)
+	}
+	if w := WaterWeight(&eco, 1); w != 0 {
+		t.Fatalf("a family with a full reserve should not look for water, weight %g", w)
+	}
+	if got := WaterForce(&eco, 2); got != (OrderedPair{}) {
+		t.Fatalf("a family at the shore is already drinking, got %v", got)
+	}
+}
+
+func TestThirstKillsInEveryModel(t *testing.T) {
+	for _, model := range sortedKeys(populationModels) {
+		cfg := NewDefaultEcosystemConfig()
+		cfg.Seed = 3
+		cfg.Dynamics.Model = model
+		cfg.Energy.Enabled = false
+		eco := Ecosystem{
+			config:           &cfg,
+			weather:          "Sunny",
+			width:            500,
+			Lake:             InitializeLake(250, 250, 50),
+			CarryingCapacity: map[string]int{},
+			Families: []Family{
+				{Size: 200, species: SpeciesRegistry["deer"], hydration: 0, Position: OrderedPair{450, 100}},
+				{Size: 200, species: SpeciesRegistry["deer"], hydration: cfg.Hydration.Max, Position: OrderedPair{50, 400}},
+			},
+		}
+		eco.indexFamilies(cfg.Movement.SeparationThreshold)
+		updateFamilyPopulations(&eco, map[int]float64{}, 1)
+
+		// An empty reserve kills DehydrationRate of the family, 100 of 200.
+		if len(eco.Families) != 2 || eco.Families[0].Size > eco.Families[1].Size-50 {
+			t.Fatalf("%s: a parched family should die of thirst, sizes %v", model, eco.Families)
+		}
+	}
+}
+
+func TestNoWaterToDrink(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Lake.Radius = 0
+	cfg.Lake.MaxRadius = 0
+	eco := BuildEcosystemFromConfig(cfg)
+	eco.Families = []Family{
+		{Size: 10, species: SpeciesRegistry["deer"], hydration: 0, Position: cfg.Lake.Center},
+	}
+
+	if len(eco.water()) != 0 {
+		t.Fatalf("a lake without a radius should be no water, got %v", eco.water())
+	}
+	if got := WaterForce(&eco, 0); got != (OrderedPair{}) {
+		t.Fatalf("without water there is nowhere to head for, got %v", got)
+	}
+	UpdateHydration(&eco, 0.1)
+	if eco.Families[0].hydration != 0 {
+		t.Fatalf("a family at the centre of a lake of no size should not drink, hydration %g", eco.Families[0].hydration)
+	}
+	if f := LakeGrowthFactor(cfg.Lake.Center, eco.water(), cfg.Vegetation); f != 1 {
+		t.Fatalf("plants should get no bonus from a lake of no size, got %g", f)
+	}
+}
+
+func TestThirstyFamiliesFindWaterAcrossTheEdge(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Waters = []WaterConfig{{Shape: "river", Points: []OrderedPair{{250, 0}, {250, 200}}, Width: 20}}
+	eco := Ecosystem{
+		config:  &cfg,
+		weather: "Sunny",
+		width:   500,
+		Lake:    InitializeLake(400, 400, 20),
+		Families: []Family{
+			{Size: 10, species: SpeciesRegistry["deer"], hydration: 0, Position: OrderedPair{250, 470}},
+			{Size: 10, species: SpeciesRegistry["deer"], hydration: 5, Position: OrderedPair{250, 485}},
+		},
+	}
+
+	// The river starts at the top edge and its bank rounds its end 10 further
+	// up, so on a torus it is 20 below the first family.
+	if got := WaterForce(&eco, 0); !almostEqual(got.x, 0, 1e-12) || !almostEqual(got.y, 1, 1e-12) {
+		t.Fatalf("the family should head down across the edge to the river, got %v", got)
+	}
+	if _, d := ShoreOffset(OrderedPair{250, 470}, eco.bodiesOfWater(), 500, BoundaryTorus); !almostEqual(d, 20, 1e-12) {
+		t.Fatalf("shore distance across the edge %g, want 20", d)
+	}
+	UpdateHydration(&eco, 0.1)
+	if want := 5 + (cfg.Hydration.DrinkRate-WaterLoss(cfg.Hydration, "Sunny"))*0.1; !almostEqual(eco.Families[1].hydration, want, 1e-12) {
+		t.Fatalf("a family 5 from the river across the edge should drink, hydration %g, want %g", eco.Families[1].hydration, want)
+	}
+
+	// Behind walls the river is only reachable the long way round.
+	cfg.Movement.Boundary = BoundaryReflect
+	if got := WaterForce(&eco, 0); got.y >= 0 {
+		t.Fatalf("behind walls the family should head up to the river, got %v", got)
+	}
+}
+
+/* ================================
+   Tests for boundary.go
+================================ */
+
+func TestApplyBoundary(t *testing.T) {
+	v := OrderedPair{-5, 3}
+	p, got, in := ApplyBoundary(OrderedPair{-2, 250}, v, 500, BoundaryTorus)
+	if !in || p != (OrderedPair{498, 250}) || got != v {
+		t.Fatalf("torus: got %v %v %v", p, got, in)
+	}
+	p, got, in = ApplyBoundary(OrderedPair{-2, 503}, OrderedPair{-5, 3}, 500, BoundaryReflect)
+	if !in || p != (OrderedPair{2, 497}) || got != (OrderedPair{5, -3}) {
+		t.Fatalf("reflect: got %v %v %v", p, got, in)
+	}
+	if _, _, in = ApplyBoundary(OrderedPair{-2, 250}, v, 500, BoundaryAbsorb); in {
+		t.Fatalf("absorb: a family past the edge should leave the world")
+	}
+	if _, _, in = ApplyBoundary(OrderedPair{2, 250}, v, 500, BoundaryAbsorb); !in {
+		t.Fatalf("absorb: a family inside the world should stay")
+	}
+}
+
+func TestOffsetFollowsTheBoundary(t *testing.T) {
+	a, b := OrderedPair{495, 10}, OrderedPair{5, 490}
+	if got := Offset(a, b, 500, BoundaryTorus); !almostEqual(got.x, 10, 1e-12) || !almostEqual(got.y, -20, 1e-12) {
+		t.Fatalf("torus: offset %v, want (10, -20)", got)
+	}
+	if got := Offset(a, b, 500, BoundaryReflect); got != (OrderedPair{-490, 480}) {
+		t.Fatalf("reflect: offset %v, want (-490, 480)", got)
+	}
+	if images := torusImages(OrderedPair{2, 250}, 500, 5); len(images) != 2 || images[1] != (OrderedPair{502, 250}) {
+		t.Fatalf("a family at the west edge should be drawn again at the east edge, got %v", images)
+	}
+}
+
+func TestBoundaryModes(t *testing.T) {
+	for _, boundary := range validBoundaries {
+		cfg := NewDefaultEcosystemConfig()
+		cfg.Seed = 3
+		cfg.Movement.Boundary = boundary
+		cfg.Plants.ConsumptionRate = 1
+		eco := BuildEcosystemFromConfig(cfg)
+
+		// A grazer on the east edge and a plant just across it on the west edge.
+		eco.Families = []Family{{Size: 10, species: SpeciesRegistry["sheep"], Position: OrderedPair{499, 100}}}
+		eco.Plants = []Plant{{position: OrderedPair{1, 100}, size: 10, maxSize: 30}}
+		eaten := ConsumePlants(&eco, 1, cfg.Population.EatingThreshold)[0]
+		if wraps := boundary == BoundaryTorus; (eaten > 0) != wraps {
+			t.Fatalf("%s: grazed %g across the edge of the world", boundary, eaten)
+		}
+
+		eco = BuildEcosystemFromConfig(cfg)
+		before := len(eco.Families)
+		for step := 0; step < 300; step++ {
+			UpdateEcosystem(&eco, cfg.Movement.TimeStep)
+			for _, f := range eco.Families {
+				if f.Position.x < 0 || f.Position.x > eco.width || f.Position.y < 0 || f.Position.y > eco.width {
+					t.Fatalf("%s: family outside the world at %v", boundary, f.Position)
+				}
+			}
+		}
+		if boundary == BoundaryAbsorb && len(eco.Families) >= before {
+			t.Fatalf("absorb: no family migrated out in 300 steps (%d families, started with %d)", len(eco.Families), before)
+		}
+	}
+}
+
+func TestPredationAcrossTheEdge(t *testing.T) {
+	for _, boundary := range []string{BoundaryTorus, BoundaryReflect} {
+		cfg := NewDefaultEcosystemConfig()
+		cfg.Movement.Boundary = boundary
+		eco := Ecosystem{
+			config: &cfg,
+			width:  500,
+			Families: []Family{
+				{Size: 20, species: SpeciesRegistry["wolf"], Position: OrderedPair{499, 250}},
+				{Size: 50, species: SpeciesRegistry["rabbit"], Position: OrderedPair{1, 250}},
+			},
+		}
+		eco.indexFamilies(cfg.Movement.SeparationThreshold)
+		step := newPopulationStep(&eco, map[int]float64{}, 2, 0.1)
+		pursuit := PursuitForce(&eco, 0)
+		sepX, _ := CalculateSeparationForce(&eco, 1)
+		if boundary == BoundaryTorus {
+			if step.Caught[0] <= 0 || step.Lost[1] <= 0 {
+				t.Fatalf("torus: the wolf at x=499 should catch the rabbit at x=1, caught %g", step.Caught[0])
+			}
+			if !almostEqual(pursuit.x, 1, 1e-12) {
+				t.Fatalf("torus: the wolf should chase east across the edge, got %v", pursuit)
+			}
+			if sepX <= 0 {
+				t.Fatalf("torus: the rabbit should be pushed east, away from the wolf across the edge, got %g", sepX)
+			}
+			if d := ComputeAveragePairwiseDistance(&eco); !almostEqual(d, 2, 1e-12) {
+				t.Fatalf("torus: average distance %g, want 2", d)
+			}
+		} else {
+			if step.Caught[0] != 0 || pursuit != (OrderedPair{}) || sepX != 0 {
+				t.Fatalf("reflect: the wall separates the wolf and the rabbit, caught %g pursuit %v separation %g", step.Caught[0], pursuit, sepX)
+			}
+		}
+	}
+}
+
+func TestDiscreteModelAcrossTheEdge(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	eco := Ecosystem{
+		config: &cfg,
+		width:  500,
+		Families: []Family{
+			{Size: 20, species: SpeciesRegistry["wolf"], Position: OrderedPair{250, 499}},
+			{Size: 50, species: SpeciesRegistry["rabbit"], Position: OrderedPair{250, 1}},
+		},
+	}
+	step := newPopulationStep(&eco, map[int]float64{}, 1, 0.1)
+	rates := discreteModel{}.GrowthRates(&eco, step)
+	wolf, rabbit := SpeciesRegistry["wolf"], SpeciesRegistry["rabbit"]
+	if !almostEqual(rates[0].Growth, wolf.GrowthRate+wolf.ContactGrowthRate, 1e-12) {
+		t.Fatalf("the wolf should get its contact rate across the edge, got %+v", rates[0])
+	}
+	// The contact costs the rabbit members, so it counts as deaths.
+	if !almostEqual(rates[1].Growth, rabbit.GrowthRate+cfg.Dynamics.PreyPlantBonus, 1e-12) || !almostEqual(rates[1].Deaths, -rabbit.ContactGrowthRate, 1e-12) {
+		t.Fatalf("the rabbit should get its contact rate across the edge, got %+v", rates[1])
+	}
+
+	// The plant bonus comes from the scenario, apart from how fast plants grow.
+	cfg.Dynamics.PreyPlantBonus = 0.3
+	cfg.Plants.GrowthCoefficient = 0.9
+	rates = discreteModel{}.GrowthRates(&eco, step)
+	if !almostEqual(rates[1].Growth, rabbit.GrowthRate+0.3, 1e-12) {
+		t.Fatalf("the rabbit should get the configured plant bonus, got %+v", rates[1])
+	}
+}
+
+func TestFlockingAcrossTheEdge(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	eco := Ecosystem{
+		config: &cfg,
+		width:  500,
+		Families: []Family{
+			{Size: 20, species: SpeciesRegistry["sheep"], Position: OrderedPair{495, 250}},
+			{Size: 20, species: SpeciesRegistry["sheep"], Position: OrderedPair{15, 250}},
+		},
+	}
+	if got := CohesionForce(&eco, 0); !almostEqual(got.x, 20/SpeciesRegistry["sheep"].PerceptionRadius, 1e-12) {
+		t.Fatalf("cohesion should pull east toward the herd across the edge, got %v", got)
+	}
+	eco.Families = append(eco.Families, Family{Size: 20, species: SpeciesRegistry["wolf"], Position: OrderedPair{5, 250}})
+	if got := EvasionForce(&eco, 0); got.x >= 0 {
+		t.Fatalf("the sheep should flee west from the wolf across the edge, got %v", got)
+	}
+}
+
+func TestSplitFamiliesStayInTheWorld(t *testing.T) {
+	for _, boundary := range []string{BoundaryTorus, BoundaryReflect, BoundaryAbsorb} {
+		cfg := NewDefaultEcosystemConfig()
+		cfg.Seed = 4
+		cfg.Movement.Boundary = boundary
+		eco := Ecosystem{width: 500, config: &cfg}
+		for i := 0; i < 20; i++ {
+			eco.Families = append(eco.Families, Family{Size: 2 * cfg.Population.MaxFamilySize, species: SpeciesRegistry["sheep"]})
+		}
+		SplitLargeFamilies(&eco)
+		if len(eco.Families) != 40 {
+			t.Fatalf("%s: every family in the corner should split, got %d families", boundary, len(eco.Families))
+		}
+		for _, f := range eco.Families {
+			if f.Position.x < 0 || f.Position.x >= 500 || f.Position.y < 0 || f.Position.y >= 500 {
+				t.Fatalf("%s: a split family landed outside the world at %v", boundary, f.Position)
+			}
+		}
+	}
+}
+
+func TestWaterAndSettlementAreDrawnAcrossTheEdge(t *testing.T) {
+	lakeColor := Color{R: 64, G: 164, B: 223}
+	settlementColor := Color{R: 160, G: 110, B: 60}
+	for _, boundary := range []string{BoundaryTorus, BoundaryReflect} {
+		cfg := NewDefaultEcosystemConfig()
+		cfg.Movement.Boundary = boundary
+		cfg.Lake.Center = OrderedPair{5, 250}
+		cfg.Lake.Radius = 30
+		cfg.Lake.MaxRadius = 30
+		cfg.Waters = []WaterConfig{{Shape: "river", Points: []OrderedPair{{150, 5}, {350, 5}}, Width: 40}}
+		eco := BuildEcosystemFromConfig(cfg)
+		eco.Families = nil
+		eco.settlement = &Settlement{Position: OrderedPair{250, 495}}
+
+		img := DrawToCanvas(eco, Config{CanvasWidth: 100})
+		same := func(x, y int, want Color) bool {
+			r, g, b, _ := img.At(x, y).RGBA()
+			return uint8(r>>8) == want.R && uint8(g>>8) == want.G && uint8(b>>8) == want.B
+		}
+		torus := boundary == BoundaryTorus
+		if same(98, 50, lakeColor) != torus {
+			t.Fatalf("%s: lake drawn on the far side of the west edge: %v", boundary, !torus)
+		}
+		if same(35, 99, lakeColor) != torus {
+			t.Fatalf("%s: river along the top edge drawn at the bottom too: %v", boundary, !torus)
+		}
+		if same(50, 2, settlementColor) != torus {
+			t.Fatalf("%s: settlement drawn at the top across the bottom edge: %v", boundary, !torus)
+		}
+	}
+}
+
+/* ================================
+   Tests for logging.go
+================================ */
+
+func TestPopulationLogListsEveryConfiguredSpecies(t *testing.T) {
+	cfg := NewDefaultEcosystemConfig()
+	cfg.Seed = 5
+	for _, name := range []string{"fox", "badger"} {
+		s := cfg.Species["wolf"]
+		s.Name = name
+		cfg.Species[name] = s
+		cfg.Population.InitialPopulations[name] = 12
+	}
+	eco := BuildEcosystemFromConfig(cfg)
+
+	header := PopulationLogHeader(&cfg)
+	want := "Generation,rabbit,sheep,deer,wolf,human,badger,fox,plant_mass,"
+	if got := strings.Join(header, ","); !strings.HasPrefix(got, want) {
+		t.Fatalf("header should keep the legacy species first and add the rest by name, got %q", got)
+	}
+	if !strings.HasSuffix(strings.Join(header, ","), "fox_juvenile,fox_adult,fox_senescent") {
+		t.Fatalf("header should end with the fox life stages, got %v", header)
+	}
+	row := PopulationLogRow(0, &eco)
+	if len(row) != len(header) {
+		t.Fatalf("row has %d columns, header has %d", len(row), len(header))
+	}
+	if row[7] != fmt.Sprint(CountSpecies(&eco)["fox"]) || row[7] == "0" {
+		t.Fatalf("fox column should hold the fox count, got %q", row[7])
+	}
+}
@@ -1,7 +1,13 @@
 package main
 
-import "math"
+import (
+	"encoding/json"
+	"math"
+)
 
+// DistanceOrdered is the plain Euclidean distance between a and b. It knows
+// nothing of the edges of the world; distances between families and plants
+// are measured with Offset instead (see boundary.go).
 func DistanceOrdered(a, b OrderedPair) float64 {
 	dx := a.x - b.x
 	dy := a.y - b.y
@@ -73,3 +79,24 @@ func LerpOrdered(a, b OrderedPair, t float64) OrderedPair {
 		y: a.y + (b.y-a.y)*t,
 	}
 }
+
+// orderedPairJSON mirrors OrderedPair with exported fields so positions can be
+// written to and read from scenario files.
+type orderedPairJSON struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+func (p OrderedPair) MarshalJSON() ([]byte, error) {
+	return json.Marshal(orderedPairJSON{X: p.x, Y: p.y})
+}
+
+func (p *OrderedPair) UnmarshalJSON(data []byte) error {
+	var v orderedPairJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	p.x = v.X
+	p.y = v.Y
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import "math"
+
+// Humans are an active species: they hunt, farm and live in a settlement.
+// Which species they hunt, and how hard, is set in the interaction matrix
+// like for any other hunter (see interactions.go). The largest human family
+// founds a settlement where it stands; humans plant crops and harvest ripe
+// plants on the farmland around it and head back when they wander off it,
+// while every other species keeps away from the settlement itself. The human
+// population does not live off what it hunts or harvests: its numbers follow
+// the growth rate of the species, so a run shows the humans' impact on the
+// ecosystem and not the other way round.
+
+// Settlement is the home of the humans.
+type Settlement struct {
+	Position  OrderedPair `json:"position"`
+	Harvested float64     `json:"harvested"` // plant mass harvested since the settlement was founded
+}
+
+// isHuman reports whether the species hunts, farms and settles as humans do.
+func (s Species) isHuman() bool {
+	return s.Type == "human"
+}
+
+// UpdateHumans founds the settlement once there are humans to found it and
+// lets every human family on the farmland plant and harvest for one step.
+func UpdateHumans(ecosystem *Ecosystem) {
+	cfg := ecosystem.settings().Humans
+	if ecosystem.settlement == nil {
+		ecosystem.settlement = FoundSettlement(ecosystem.Families, ecosystem.water(), cfg)
+		if ecosystem.settlement == nil {
+			return
+		}
+		// Pushed out of water that touches an edge, the settlement may lie beyond it.
+		ecosystem.settlement.Position, _, _ = ApplyBoundary(ecosystem.settlement.Position, OrderedPair{}, ecosystem.width, ecosystem.boundary())
+	}
+
+	rng := ecosystem.random()
+	water := ecosystem.water()
+	for i := range ecosystem.Families {
+		f := ecosystem.Families[i]
+		if !f.species.isHuman() || f.Size <= 0 || !onFarmland(f.Position, ecosystem.settlement, ecosystem.settings()) {
+			continue
+		}
+		if rng.Float64() < cfg.PlantingChance {
+			ecosystem.Plants = PlantCrop(ecosystem.Plants, f.Position, water, ecosystem.settings())
+		}
+		ecosystem.settlement.Harvested += Harvest(ecosystem.Plants, f, ecosystem.settlement, ecosystem.settings())
+	}
+}
+
+// FoundSettlement returns a settlement at the position of the largest human
+// family (the first one on a tie), moved out of the water, or nil when there
+// are no humans or settlements are disabled.
+func FoundSettlement(families []Family, water WaterBody, cfg HumanConfig) *Settlement {
+	if cfg.SettlementRadius <= 0 {
+		return nil
+	}
+	founder := -1
+	for i, f := range families {
+		if f.species.isHuman() && f.Size > 0 && (founder < 0 || f.Size > families[founder].Size) {
+			founder = i
+		}
+	}
+	if founder < 0 {
+		return nil
+	}
+	return &Settlement{Position: PushOutOfWater(families[founder].Position, water)}
+}
+
+// onFarmland reports whether the position lies within FarmRadius of the settlement.
+func onFarmland(position OrderedPair, settlement *Settlement, cfg *EcosystemConfig) bool {
+	return settlement != nil && cfg.distanceBetween(position, settlement.Position) <= cfg.Humans.FarmRadius
+}
+
+// PlantCrop sows a crop at the given spot: a seed that grows toward CropSize.
+// Like a wild seed (see SpreadSeeds) it is lost in the water, revives a dead
+// plant next to it, is crowded out by a living one and otherwise starts a new
+// plant as long as there are fewer than MaxPlants plants.
+func PlantCrop(plants []Plant, spot OrderedPair, water WaterBody, cfg *EcosystemConfig) []Plant {
+	if water.Contains(spot) {
+		return plants
+	}
+	dead := -1
+	for j, p := range plants {
+		if cfg.distanceBetween(spot, p.position) >= cfg.Vegetation.MinSpacing {
+			continue
+		}
+		if p.size > 0 {
+			return plants
+		}
+		if dead < 0 {
+			dead = j
+		}
+	}
+	if dead >= 0 {
+		plants[dead] = Plant{position: plants[dead].position, size: cfg.Plants.SeedSize, maxSize: cfg.Humans.CropSize}
+		return plants
+	}
+	if len(plants) >= cfg.Vegetation.MaxPlants {
+		return plants
+	}
+	return append(plants, Plant{position: spot, size: cfg.Plants.SeedSize, maxSize: cfg.Humans.CropSize})
+}
+
+// Harvest lets a human family take up to HarvestRate plant mass per member
+// from the ripe plants (half their maximum or more) on the farmland within
+// the eating threshold of it, in plant order. A harvested plant keeps its
+// seed size so it grows back. It returns the mass harvested.
+func Harvest(plants []Plant, f Family, settlement *Settlement, cfg *EcosystemConfig) float64 {
+	want := cfg.Humans.HarvestRate * float64(f.Size)
+	harvested := 0.0
+	for j := range plants {
+		if want <= 0 {
+			break
+		}
+		p := &plants[j]
+		capacity := p.maxSize
+		if capacity <= 0 {
+			capacity = cfg.Plants.MaxSize
+		}
+		if p.size < capacity/2 || cfg.distanceBetween(f.Position, p.position) >= cfg.Population.EatingThreshold || !onFarmland(p.position, settlement, cfg) {
+			continue
+		}
+		take := math.Min(want, p.size-cfg.Plants.SeedSize)
+		if take <= 0 {
+			continue
+		}
+		p.size -= take
+		want -= take
+		harvested += take
+	}
+	return harvested
+}
+
+// SettlementForce keeps families around the settlement. Other species are
+// pushed away from it: fully inside it, fading out PerceptionRadius beyond its
+// edge. Humans are pulled back toward it once they leave the farmland, at
+// full strength FarmRadius beyond it. The force is zero without a settlement
+// and has at most unit strength.
+func SettlementForce(ecosystem *Ecosystem, i int) OrderedPair {
+	s := ecosystem.settlement
+	if s == nil {
+		return OrderedPair{}
+	}
+	cfg := ecosystem.settings().Humans
+	f := ecosystem.Families[i]
+	toHome := ecosystem.offset(f.Position, s.Position)
+	d := NormOrdered(toHome)
+	if d == 0 {
+		return OrderedPair{}
+	}
+
+	if f.species.isHuman() {
+		if d <= cfg.FarmRadius || cfg.FarmRadius <= 0 {
+			return OrderedPair{}
+		}
+		return ScaleOrdered(toHome, math.Min(1, (d-cfg.FarmRadius)/cfg.FarmRadius)/d)
+	}
+
+	urgency := 1.0
+	if beyond := d - cfg.SettlementRadius; beyond > 0 {
+		radius := f.species.PerceptionRadius
+		if radius <= 0 || beyond >= radius {
+			return OrderedPair{}
+		}
+		urgency = 1 - beyond/radius
+	}
+	return ScaleOrdered(toHome, -urgency/d)
+}
+
+// SettlementWeight is the weight of the settlement force for family i.
+func SettlementWeight(ecosystem *Ecosystem, i int) float64 {
+	cfg := ecosystem.settings().Humans
+	if ecosystem.Families[i].species.isHuman() {
+		return cfg.HomeWeight
+	}
+	return cfg.SettlementAvoidance
+}
@@ -0,0 +1,80 @@
+package main
+
+// Every prey and predator family also carries a hydration reserve per
+// individual. It runs down all the time, faster in Dry and Sunny weather, and
+// fills up again while the family is within DrinkDistance of a shore, so a
+// lake that shrinks in a drought leaves the families far from it thirsty. A
+// thirsty family heads for the nearest shore and a family whose reserve runs
+// low dies of thirst. Like the energy reserve it leaves neutral and human
+// families alone.
+
+// needsWater reports whether the family has a hydration reserve.
+func needsWater(f Family, cfg HydrationConfig) bool {
+	return cfg.Enabled && f.species.Type != "neutral" && !f.species.isHuman()
+}
+
+// thirst is 0 for a family with a full hydration reserve and 1 for an empty one.
+func (f Family) thirst(cfg HydrationConfig) float64 {
+	if cfg.Max <= 0 {
+		return 0
+	}
+	return min(1, max(0, 1-f.hydration/cfg.Max))
+}
+
+// WaterLoss returns the hydration one individual loses per unit of time in the given weather.
+func WaterLoss(cfg HydrationConfig, weather string) float64 {
+	return cfg.LossRate * (1 + CoefficientOfThirstIncrease(weather))
+}
+
+// UpdateHydration takes a step's worth of water from every family and lets
+// the families at a shore drink.
+func UpdateHydration(ecosystem *Ecosystem, timeStep float64) {
+	h := ecosystem.settings().Hydration
+	water := ecosystem.bodiesOfWater()
+	loss := WaterLoss(h, ecosystem.weather)
+	for i := range ecosystem.Families {
+		f := &ecosystem.Families[i]
+		if !needsWater(*f, h) || f.Size <= 0 {
+			continue
+		}
+		change := -loss
+		if _, d := ShoreOffset(f.Position, water, ecosystem.width, ecosystem.boundary()); d <= h.DrinkDistance {
+			change += h.DrinkRate
+		}
+		f.hydration = min(h.Max, max(0, f.hydration+change*timeStep))
+	}
+}
+
+// HydrationGrowthRate returns the per capita death rate of thirst: nothing
+// above the dehydration threshold, growing linearly to DehydrationRate as the
+// reserve runs dry.
+func HydrationGrowthRate(f Family, cfg HydrationConfig) float64 {
+	if f.hydration >= cfg.DehydrationThreshold {
+		return 0
+	}
+	return -cfg.DehydrationRate * (1 - f.hydration/cfg.DehydrationThreshold)
+}
+
+// WaterForce returns the unit vector from a thirsty family toward the nearest
+// shore, which on a torus may lie across an edge of the world. It is zero for
+// families without a hydration reserve and for those already close enough to
+// drink.
+func WaterForce(ecosystem *Ecosystem, i int) OrderedPair {
+	f := ecosystem.Families[i]
+	h := ecosystem.settings().Hydration
+	if !needsWater(f, h) {
+		return OrderedPair{}
+	}
+	toShore, d := ShoreOffset(f.Position, ecosystem.bodiesOfWater(), ecosystem.width, ecosystem.boundary())
+	if d <= h.DrinkDistance {
+		return OrderedPair{}
+	}
+	return NormalizeOrdered(toShore)
+}
+
+// WaterWeight is the weight of the water force for family i: nothing for a
+// family with a full reserve, rising to ThirstWeight for a parched one.
+func WaterWeight(ecosystem *Ecosystem, i int) float64 {
+	h := ecosystem.settings().Hydration
+	return h.ThirstWeight * ecosystem.Families[i].thirst(h)
+}
@@ -0,0 +1,90 @@
+package main
+
+// Which species eat, compete with or keep away from which other species is
+// set per pair of species in EcosystemConfig.Interactions, so any food web can
+// be simulated: wolves that eat deer but not sheep, omnivores that graze and
+// hunt, or humans that hunt wolves. A species' Type only says whether it grazes
+// (see eatsPlants) and whether it has an energy reserve; the default matrix is
+// derived from the types (see DefaultInteractions).
+
+// Interaction is what members of one species do to members of another.
+type Interaction struct {
+	Predation   float64 `json:"predation"`   // attack rate, see FunctionalResponse; 0 means the species does not eat the other
+	Competition float64 `json:"competition"` // how much each member of the other species counts against this species' carrying capacity
+	Avoidance   float64 `json:"avoidance"`   // how strongly this species flees and keeps its distance from the other
+}
+
+// InteractionMatrix is keyed by the name of the acting species and then by
+// the name of the species it acts on. Pairs that are not listed do not interact.
+type InteractionMatrix map[string]map[string]Interaction
+
+// defaultAttackRate is the attack rate of predators on prey in the default matrix.
+const defaultAttackRate = 0.01
+
+// Between returns what species a does to species b.
+func (m InteractionMatrix) Between(a, b string) Interaction {
+	return m[a][b]
+}
+
+// Clone returns a deep copy of the matrix.
+func (m InteractionMatrix) Clone() InteractionMatrix {
+	if m == nil {
+		return nil
+	}
+	c := make(InteractionMatrix, len(m))
+	for a, row := range m {
+		c[a] = make(map[string]Interaction, len(row))
+		for b, in := range row {
+			c[a][b] = in
+		}
+	}
+	return c
+}
+
+// DefaultInteractions derives the matrix from the species types: predators
+// and humans eat every prey species at defaultAttackRate, prey avoid every
+// predator species and every other species avoids humans. Nothing competes.
+func DefaultInteractions(species map[string]Species) InteractionMatrix {
+	m := make(InteractionMatrix)
+	for _, a := range sortedKeys(species) {
+		for _, b := range sortedKeys(species) {
+			var in Interaction
+			switch {
+			case (species[a].Type == "predator" || species[a].isHuman()) && species[b].Type == "prey":
+				in.Predation = defaultAttackRate
+			case species[a].Type == "prey" && species[b].Type == "predator",
+				!species[a].isHuman() && species[b].isHuman():
+				in.Avoidance = 1
+			default:
+				continue
+			}
+			if m[a] == nil {
+				m[a] = make(map[string]Interaction)
+			}
+			m[a][b] = in
+		}
+	}
+	return m
+}
+
+// eatsPlants reports whether the species grazes.
+func (s Species) eatsPlants() bool {
+	return s.Type == "prey" || s.Type == "omnivore"
+}
+
+// competingCounts returns for every species the number of individuals that
+// count against its carrying capacity: its own members plus the members of
+// the species it competes with, weighted by the competition coefficients.
+func competingCounts(counts map[string]int, m InteractionMatrix) map[string]float64 {
+	competing := make(map[string]float64, len(counts))
+	for a, n := range counts {
+		competing[a] += float64(n)
+		// Summed in name order, so runs are reproducible to the last bit.
+		for _, b := range sortedKeys(m[a]) {
+			if b != a {
+				competing[a] += m[a][b].Competition * float64(counts[b])
+			}
+		}
+	}
+	return competing
+}
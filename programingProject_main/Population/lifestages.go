@@ -0,0 +1,171 @@
+package main
+
+import "math/rand/v2"
+
+// Families are made up of three age cohorts. Newborns are juveniles, which do
+// not breed; after MaturationAge on average they become adults, the only
+// members that have young; from SenescenceAge on they are senescent and die
+// of old age around Lifespan. Ages are in units of simulation time. A species
+// without a Lifespan has no age structure: every member counts as an adult
+// and breeds at the species rate.
+
+// LifeStage is one of the age cohorts of a family.
+type LifeStage int
+
+const (
+	Juvenile LifeStage = iota
+	Adult
+	Senescent
+	numLifeStages
+)
+
+// lifeStageNames are the names of the stages in the population log.
+var lifeStageNames = [numLifeStages]string{"juvenile", "adult", "senescent"}
+
+func (s LifeStage) String() string {
+	return lifeStageNames[s]
+}
+
+// hasLifeStages reports whether the species ages through the three stages.
+func (s Species) hasLifeStages() bool {
+	return s.Lifespan > 0
+}
+
+// initialStages splits a new family of the given size over the stages in
+// proportion to how long a member spends in each of them.
+func initialStages(size int, s Species) [numLifeStages]int {
+	if !s.hasLifeStages() || size <= 0 {
+		return [numLifeStages]int{Adult: max(0, size)}
+	}
+	juveniles := int(float64(size)*s.MaturationAge/s.Lifespan + 0.5)
+	senescent := int(float64(size)*(s.Lifespan-s.SenescenceAge)/s.Lifespan + 0.5)
+	if juveniles+senescent > size {
+		senescent = size - juveniles
+	}
+	return [numLifeStages]int{juveniles, size - juveniles - senescent, senescent}
+}
+
+// StageCounts returns the number of members in each stage. A family whose
+// stages do not add up to its size, such as one built by hand, counts as all
+// adults.
+func (f Family) StageCounts() [numLifeStages]int {
+	sum := 0
+	for _, n := range f.stages {
+		sum += n
+	}
+	if sum != f.Size {
+		return [numLifeStages]int{Adult: max(0, f.Size)}
+	}
+	return f.stages
+}
+
+// breedingSize is the number of members the family's birth rate applies to:
+// its adults weighted by the species fecundity, or all members for a species
+// without an age structure.
+func (f Family) breedingSize() float64 {
+	if !f.species.hasLifeStages() {
+		return float64(f.Size)
+	}
+	return float64(f.StageCounts()[Adult]) * f.species.Fecundity
+}
+
+// addMembers adds n newborns to the family, or removes -n members picked at
+// random over all stages when n is negative.
+func (f *Family) addMembers(n int, rng *rand.Rand) {
+	f.stages = f.StageCounts()
+	if n >= 0 {
+		if f.species.hasLifeStages() && f.species.MaturationAge > 0 {
+			f.stages[Juvenile] += n
+		} else {
+			f.stages[Adult] += n
+		}
+		f.Size += n
+		return
+	}
+	for ; n < 0 && f.Size > 0; n++ {
+		pick := rng.IntN(f.Size)
+		for s := range f.stages {
+			if pick < f.stages[s] {
+				f.stages[s]--
+				break
+			}
+			pick -= f.stages[s]
+		}
+		f.Size--
+	}
+}
+
+// AgeFamily moves members on to the next stage for one step: juveniles mature
+// at rate 1/MaturationAge, adults become senescent at rate
+// 1/(SenescenceAge-MaturationAge) and senescent members die of old age at rate
+// 1/(Lifespan-SenescenceAge).
+func AgeFamily(f *Family, timeStep float64, rng *rand.Rand) {
+	s := f.species
+	if !s.hasLifeStages() || f.Size <= 0 {
+		return
+	}
+	f.stages = f.StageCounts()
+	// Each transition is worked out from the counts at the start of the step,
+	// so a member moves on by at most one stage.
+	var matured int
+	if s.MaturationAge > 0 {
+		matured = min(f.stages[Juvenile], probabilisticRound(float64(f.stages[Juvenile])*timeStep/s.MaturationAge, rng))
+	}
+	aged := min(f.stages[Adult], probabilisticRound(float64(f.stages[Adult])*timeStep/(s.SenescenceAge-s.MaturationAge), rng))
+	died := min(f.stages[Senescent], probabilisticRound(float64(f.stages[Senescent])*timeStep/(s.Lifespan-s.SenescenceAge), rng))
+
+	f.stages[Juvenile] -= matured
+	f.stages[Adult] += matured - aged
+	f.stages[Senescent] += aged - died
+	f.Size -= died
+}
+
+// splitStages divides the stages of a family that splits in two: the first
+// result keeps half of every stage, rounded down, and the second the rest.
+func splitStages(stages [numLifeStages]int) (kept, split [numLifeStages]int) {
+	for s, n := range stages {
+		kept[s] = n / 2
+		split[s] = n - n/2
+	}
+	return kept, split
+}
+
+// mergeStages adds up the stages of two families that merge.
+func mergeStages(a, b Family) [numLifeStages]int {
+	sa, sb := a.StageCounts(), b.StageCounts()
+	for s := range sa {
+		sa[s] += sb[s]
+	}
+	return sa
+}
+
+// CountStages returns the number of members of each species in each stage.
+func CountStages(ecosystem *Ecosystem) map[string][numLifeStages]int {
+	counts := make(map[string][numLifeStages]int)
+	for _, f := range ecosystem.Families {
+		c := counts[f.species.Name]
+		for s, n := range f.StageCounts() {
+			c[s] += n
+		}
+		counts[f.species.Name] = c
+	}
+	return counts
+}
+
+// probabilisticRound rounds x down or up at random, up with a chance equal to
+// its fractional part, so the result is x on average.
+func probabilisticRound(x float64, rng *rand.Rand) int {
+	n := int(x)
+	frac := x - float64(n)
+	if frac < 0 {
+		frac = -frac
+	}
+	if rng.Float64() < frac {
+		if x > 0 {
+			n++
+		} else {
+			n--
+		}
+	}
+	return n
+}
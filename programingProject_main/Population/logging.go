@@ -1,11 +1,29 @@
 package main
 
 import (
+	"encoding/csv"
 	"fmt"
+	"io"
 	"sort"
+	"strconv"
 	"strings"
 )
 
+// legacyLogSpecies are the species app.R reads from population_log.csv, in the order it expects.
+var legacyLogSpecies = []string{"rabbit", "sheep", "deer", "wolf", "human"}
+
+// populationLogSpecies returns the species columns of population_log.csv: the
+// legacy species first, then every other species of the scenario by name.
+func populationLogSpecies(cfg *EcosystemConfig) []string {
+	names := append([]string(nil), legacyLogSpecies...)
+	for _, name := range sortedKeys(cfg.Species) {
+		if !containsString(legacyLogSpecies, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
 func FormatPopulationLine(step int, ecosystem *Ecosystem) string {
 	summary := BuildPopulationSummary(ecosystem)
 	names := make([]string, 0, len(summary.SpeciesCounts))
@@ -45,3 +63,56 @@ func PrintWeatherSummary(step int, ecosystem *Ecosystem) {
 	line := FormatWeatherLine(step, ecosystem)
 	fmt.Println(line)
 }
+
+// PopulationLogHeader returns the header row of population_log.csv for a run
+// of cfg. The life stage counts of every species, such as rabbit_juvenile,
+// follow plant_mass.
+func PopulationLogHeader(cfg *EcosystemConfig) []string {
+	species := populationLogSpecies(cfg)
+	header := []string{"Generation"}
+	header = append(header, species...)
+	header = append(header, "plant_mass")
+	for _, name := range species {
+		for _, stage := range lifeStageNames {
+			header = append(header, name+"_"+stage)
+		}
+	}
+	return header
+}
+
+// PopulationLogRow returns the population_log.csv row for one generation.
+func PopulationLogRow(generation int, ecosystem *Ecosystem) []string {
+	species := populationLogSpecies(ecosystem.settings())
+	counts := CountSpecies(ecosystem)
+	row := []string{strconv.Itoa(generation)}
+	for _, name := range species {
+		row = append(row, strconv.Itoa(counts[name]))
+	}
+	row = append(row, strconv.FormatFloat(CountPlantMass(ecosystem), 'f', 2, 64))
+	stages := CountStages(ecosystem)
+	for _, name := range species {
+		for _, n := range stages[name] {
+			row = append(row, strconv.Itoa(n))
+		}
+	}
+	return row
+}
+
+// WritePopulationCSV writes the population log of every n-th time point to w.
+func WritePopulationCSV(w io.Writer, timePoints []Ecosystem, every int) error {
+	cfg := &defaultEcosystemConfig
+	if len(timePoints) > 0 {
+		cfg = timePoints[0].settings()
+	}
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(PopulationLogHeader(cfg)); err != nil {
+		return err
+	}
+	for i := 0; i < len(timePoints); i += every {
+		if err := csvWriter.Write(PopulationLogRow(i, &timePoints[i])); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"math/rand/v2"
+	"sync"
+)
+
+// The movement, interaction and grazing phases of a step are split over
+// EcosystemConfig.Workers goroutines. Every worker always gets the same
+// contiguous chunk of indices, whatever order the goroutines run in, and a
+// worker that needs random numbers draws them from its own stream. A run
+// therefore depends only on its seed and its worker count.
+
+// workers returns the number of goroutines the ecosystem's steps use.
+func (e *Ecosystem) workers() int {
+	return max(1, e.settings().Workers)
+}
+
+// workerStreams returns one random stream per worker for the current step.
+// A single worker uses the ecosystem's own stream, so sequential runs are
+// unchanged. Otherwise every stream is seeded from the ecosystem's stream;
+// nothing but that stream has to be saved in a checkpoint.
+func (e *Ecosystem) workerStreams() []*rand.Rand {
+	n := e.workers()
+	rng := e.random()
+	if n == 1 {
+		return []*rand.Rand{rng}
+	}
+	streams := make([]*rand.Rand, n)
+	for w := range streams {
+		streams[w] = rand.New(rand.NewPCG(rng.Uint64(), rng.Uint64()))
+	}
+	return streams
+}
+
+// parallelChunks splits [0, n) into `workers` contiguous chunks and calls fn
+// for each of them on its own goroutine, then waits for all of them.
+func parallelChunks(workers, n int, fn func(worker, lo, hi int)) {
+	if workers <= 1 {
+		fn(0, 0, n)
+		return
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		lo, hi := w*n/workers, (w+1)*n/workers
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(w, lo, hi)
+		}()
+	}
+	wg.Wait()
+}
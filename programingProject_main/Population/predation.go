@@ -0,0 +1,70 @@
+package main
+
+// Predators catch prey individuals, not a flat contact rate: every member of
+// a predator family catches FunctionalResponse(n) prey per unit of time, n
+// being the prey within the eating threshold of the family. With a handling
+// time the catch saturates at 1/HandlingTime prey per predator, however many
+// prey there are. A family shares its catch among the prey families within
+// its reach by how often it meets them (attack rate times size), so a pack of
+// 100 wolves eats 50 times what a pair does and every prey a predator catches
+// is one its prey family loses. Which species eat which, and at what attack
+// rate, comes from the interaction matrix (see interactions.go); a family can
+// be predator and prey at the same time.
+
+// Predation returns the prey each family catches and the members it loses to
+// predators, both per unit of time. A family never loses more than its size
+// within timeStep; its predators then share what there is. Both slices add up
+// to the same total.
+func Predation(families []Family, neighbours [][]int, interactions InteractionMatrix, handlingTime float64, response int, timeStep float64) (caught, lost []float64) {
+	// The prey within reach of every family, how often its members meet them
+	// and how many of them the family catches per unit of time.
+	available := make([]float64, len(families))
+	encounters := make([]float64, len(families))
+	demand := make([]float64, len(families))
+	for i, f := range families {
+		for _, j := range neighbours[i] {
+			if a := interactions.Between(f.species.Name, families[j].species.Name).Predation; a > 0 {
+				available[i] += float64(families[j].Size)
+				encounters[i] += a * float64(families[j].Size)
+			}
+		}
+		if available[i] > 0 {
+			// With several kinds of prey the family hunts at their mean attack rate.
+			demand[i] = float64(f.Size) * FunctionalResponse(available[i], encounters[i]/available[i], handlingTime, response)
+		}
+	}
+
+	// What each family would lose, and the share of it that it can give.
+	lost = make([]float64, len(families))
+	for i, f := range families {
+		if demand[i] == 0 {
+			continue
+		}
+		for _, j := range neighbours[i] {
+			if a := interactions.Between(f.species.Name, families[j].species.Name).Predation; a > 0 {
+				lost[j] += demand[i] * a * float64(families[j].Size) / encounters[i]
+			}
+		}
+	}
+	share := make([]float64, len(families))
+	for j, l := range lost {
+		share[j] = 1
+		if l*timeStep > float64(families[j].Size) {
+			share[j] = float64(families[j].Size) / (l * timeStep)
+			lost[j] *= share[j]
+		}
+	}
+
+	caught = make([]float64, len(families))
+	for i, f := range families {
+		if demand[i] == 0 {
+			continue
+		}
+		for _, j := range neighbours[i] {
+			if a := interactions.Between(f.species.Name, families[j].species.Name).Predation; a > 0 {
+				caught[i] += demand[i] * a * float64(families[j].Size) / encounters[i] * share[j]
+			}
+		}
+	}
+	return caught, lost
+}
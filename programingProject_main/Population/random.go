@@ -0,0 +1,37 @@
+package main
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Every random decision in the simulation draws from the ecosystem's own PCG
+// stream, so two runs with the same seed and config are identical. The PCG
+// source is kept next to the generator because it can be copied and saved.
+
+// rngStream is the fixed second half of the PCG seed; the first half is the run seed.
+const rngStream = 0x9e3779b97f4a7c15
+
+// newRandomSource returns a generator seeded with the given seed together with its source.
+func newRandomSource(seed int64) (*rand.Rand, *rand.PCG) {
+	src := rand.NewPCG(uint64(seed), rngStream)
+	return rand.New(src), src
+}
+
+// NewRandomSeed picks a seed from the clock for runs that did not ask for one.
+func NewRandomSeed() int64 {
+	seed := time.Now().UnixNano()
+	if seed == 0 {
+		seed = 1
+	}
+	return seed
+}
+
+// random returns the ecosystem's random stream. Ecosystems that were not built
+// from a config (for example in tests) get a clock-seeded stream on first use.
+func (e *Ecosystem) random() *rand.Rand {
+	if e.rng == nil {
+		e.rng, e.rngSource = newRandomSource(NewRandomSeed())
+	}
+	return e.rng
+}
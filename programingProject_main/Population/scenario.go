@@ -0,0 +1,525 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario files describe a full EcosystemConfig in JSON or YAML. Any field that
+// is left out keeps its value from NewDefaultEcosystemConfig, except for the maps
+// (species, initial populations, carrying capacities), which replace the defaults
+// as a whole when present so a scenario can drop species it does not need.
+
+var validSpeciesTypes = []string{"predator", "prey", "omnivore", "neutral", "human"}
+var validWeathers = []string{"Dry", "Sunny", "Rainy", "Frozen"}
+var validWaterShapes = []string{"circle", "polygon", "river"}
+var validBoundaries = []string{BoundaryTorus, BoundaryReflect, BoundaryAbsorb}
+
+// LoadScenario reads a scenario file and returns the validated configuration.
+// The format is chosen from the file extension (.json, .yaml or .yml).
+func LoadScenario(path string) (EcosystemConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return EcosystemConfig{}, err
+	}
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	cfg, err := ParseScenario(data, format)
+	if err != nil {
+		return EcosystemConfig{}, fmt.Errorf("scenario %s: %w", path, err)
+	}
+	// A terrain file is read relative to the scenario and replaces any map in it.
+	if cfg.Terrain.File != "" {
+		terrainPath := cfg.Terrain.File
+		if !filepath.IsAbs(terrainPath) {
+			terrainPath = filepath.Join(filepath.Dir(path), terrainPath)
+		}
+		if cfg.Terrain.Map, err = LoadTerrainMap(terrainPath); err != nil {
+			return EcosystemConfig{}, fmt.Errorf("scenario %s: %w", path, err)
+		}
+	}
+	return cfg, nil
+}
+
+// ParseScenario decodes scenario data in the given format ("json", "yaml" or "yml")
+// on top of the default configuration and validates the result.
+func ParseScenario(data []byte, format string) (EcosystemConfig, error) {
+	switch format {
+	case "json":
+	case "yaml", "yml":
+		// YAML is converted to JSON so both formats share the same field names
+		// and the same strict decoding rules.
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return EcosystemConfig{}, fmt.Errorf("invalid YAML: %w", err)
+		}
+		if doc == nil {
+			doc = map[string]interface{}{}
+		}
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return EcosystemConfig{}, fmt.Errorf("invalid YAML: %w", err)
+		}
+		data = converted
+	default:
+		return EcosystemConfig{}, fmt.Errorf("unsupported scenario format %q (use json or yaml)", format)
+	}
+
+	defaults := NewDefaultEcosystemConfig()
+	cfg := defaults.Clone()
+	cfg.Species = nil
+	cfg.Interactions = nil
+	cfg.Population.InitialPopulations = nil
+	cfg.Population.CarryingCapacities = nil
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return EcosystemConfig{}, fmt.Errorf("invalid scenario: %w", err)
+	}
+
+	if cfg.Species == nil {
+		cfg.Species = defaults.Species
+	}
+	if cfg.Population.InitialPopulations == nil {
+		cfg.Population.InitialPopulations = defaults.Population.InitialPopulations
+	}
+	if cfg.Population.CarryingCapacities == nil {
+		cfg.Population.CarryingCapacities = defaults.Population.CarryingCapacities
+	}
+	// The map key is the species name, so the name field may be omitted in the file.
+	for key, s := range cfg.Species {
+		if s.Name == "" {
+			s.Name = key
+			cfg.Species[key] = s
+		}
+	}
+	// Without an interaction matrix the species interact as their types say.
+	if cfg.Interactions == nil {
+		cfg.Interactions = DefaultInteractions(cfg.Species)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return EcosystemConfig{}, err
+	}
+	return cfg, nil
+}
+
+// SaveScenario writes the configuration as an indented JSON scenario file.
+func SaveScenario(path string, cfg EcosystemConfig) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Validate checks the configuration for values the simulation cannot run with.
+// All problems are reported together, one per line.
+func (c EcosystemConfig) Validate() error {
+	var errs []error
+	fail := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Workers < 1 {
+		fail("workers", "must be at least 1, got %d", c.Workers)
+	}
+	if c.Width <= 0 {
+		fail("width", "must be positive, got %g", c.Width)
+	}
+
+	// Species definitions
+	if len(c.Species) == 0 {
+		fail("species", "at least one species must be defined")
+	}
+	for _, key := range sortedKeys(c.Species) {
+		s := c.Species[key]
+		if s.Name != key {
+			fail("species."+key+".name", "must match its key, got %q", s.Name)
+		}
+		if !containsString(validSpeciesTypes, s.Type) {
+			fail("species."+key+".type", "must be one of %s, got %q", strings.Join(validSpeciesTypes, ", "), s.Type)
+		}
+		if s.PerceptionRadius < 0 {
+			fail("species."+key+".perception_radius", "must not be negative, got %g", s.PerceptionRadius)
+		}
+		if s.CohesionWeight < 0 {
+			fail("species."+key+".cohesion_weight", "must not be negative, got %g", s.CohesionWeight)
+		}
+		if s.AlignmentWeight < 0 {
+			fail("species."+key+".alignment_weight", "must not be negative, got %g", s.AlignmentWeight)
+		}
+		if s.Lifespan < 0 {
+			fail("species."+key+".lifespan", "must not be negative, got %g", s.Lifespan)
+		}
+		if s.Lifespan > 0 {
+			if s.MaturationAge < 0 {
+				fail("species."+key+".maturation_age", "must not be negative, got %g", s.MaturationAge)
+			}
+			if s.SenescenceAge <= s.MaturationAge || s.SenescenceAge >= s.Lifespan {
+				fail("species."+key+".senescence_age", "must be between maturation_age (%g) and lifespan (%g), got %g", s.MaturationAge, s.Lifespan, s.SenescenceAge)
+			}
+			if s.Fecundity < 0 {
+				fail("species."+key+".fecundity", "must not be negative, got %g", s.Fecundity)
+			}
+		}
+	}
+
+	// Interactions
+	for _, a := range sortedKeys(c.Interactions) {
+		if _, ok := c.Species[a]; !ok {
+			fail("interactions."+a, "species %q is not defined", a)
+		}
+		for _, b := range sortedKeys(c.Interactions[a]) {
+			field := "interactions." + a + "." + b
+			if _, ok := c.Species[b]; !ok {
+				fail(field, "species %q is not defined", b)
+			}
+			in := c.Interactions[a][b]
+			if in.Predation < 0 {
+				fail(field+".predation", "must not be negative, got %g", in.Predation)
+			}
+			if in.Competition < 0 {
+				fail(field+".competition", "must not be negative, got %g", in.Competition)
+			}
+			if in.Avoidance < 0 {
+				fail(field+".avoidance", "must not be negative, got %g", in.Avoidance)
+			}
+		}
+	}
+
+	// Movement
+	if c.Movement.MaxSpeed <= 0 {
+		fail("movement.max_speed", "must be positive, got %g", c.Movement.MaxSpeed)
+	}
+	if c.Movement.TimeStep <= 0 {
+		fail("movement.time_step", "must be positive, got %g", c.Movement.TimeStep)
+	}
+	if c.Movement.SeparationWeight < 0 {
+		fail("movement.separation_weight", "must not be negative, got %g", c.Movement.SeparationWeight)
+	}
+	if c.Movement.SeparationThreshold < 0 {
+		fail("movement.separation_threshold", "must not be negative, got %g", c.Movement.SeparationThreshold)
+	}
+	if c.Movement.InitialSpeed < 0 {
+		fail("movement.initial_speed", "must not be negative, got %g", c.Movement.InitialSpeed)
+	}
+	if c.Movement.PursuitWeight < 0 {
+		fail("movement.pursuit_weight", "must not be negative, got %g", c.Movement.PursuitWeight)
+	}
+	if c.Movement.EvasionWeight < 0 {
+		fail("movement.evasion_weight", "must not be negative, got %g", c.Movement.EvasionWeight)
+	}
+	if c.Movement.Boundary != "" && !containsString(validBoundaries, c.Movement.Boundary) {
+		fail("movement.boundary", "must be one of %s, got %q", strings.Join(validBoundaries, ", "), c.Movement.Boundary)
+	}
+
+	// Population
+	p := c.Population
+	for _, name := range sortedKeys(p.InitialPopulations) {
+		if _, ok := c.Species[name]; !ok {
+			fail("population.initial_populations."+name, "species %q is not defined", name)
+		}
+		if p.InitialPopulations[name] < 0 {
+			fail("population.initial_populations."+name, "must not be negative, got %d", p.InitialPopulations[name])
+		}
+	}
+	for _, name := range sortedKeys(p.CarryingCapacities) {
+		if _, ok := c.Species[name]; !ok {
+			fail("population.carrying_capacities."+name, "species %q is not defined", name)
+		}
+		if p.CarryingCapacities[name] < 0 {
+			fail("population.carrying_capacities."+name, "must not be negative (0 means unlimited), got %d", p.CarryingCapacities[name])
+		}
+	}
+	if p.FamiliesPerSpecies < 1 {
+		fail("population.families_per_species", "must be at least 1, got %d", p.FamiliesPerSpecies)
+	}
+	if p.MinFamilySize < 1 {
+		fail("population.min_family_size", "must be at least 1, got %d", p.MinFamilySize)
+	}
+	if p.MaxFamilySize <= p.MinFamilySize {
+		fail("population.max_family_size", "must be greater than min_family_size (%d), got %d", p.MinFamilySize, p.MaxFamilySize)
+	}
+	if p.MergingThreshold < 0 {
+		fail("population.merging_threshold", "must not be negative, got %g", p.MergingThreshold)
+	}
+	if p.EatingThreshold < 0 {
+		fail("population.eating_threshold", "must not be negative, got %g", p.EatingThreshold)
+	}
+
+	// Population dynamics
+	d := c.Dynamics
+	if _, ok := populationModels[d.Model]; !ok {
+		fail("dynamics.model", "must be one of %s, got %q", strings.Join(populationModelNames(), ", "), d.Model)
+	}
+	if d.HandlingTime < 0 {
+		fail("dynamics.handling_time", "must not be negative, got %g", d.HandlingTime)
+	}
+	if d.ConversionEfficiency < 0 {
+		fail("dynamics.conversion_efficiency", "must not be negative, got %g", d.ConversionEfficiency)
+	}
+	if d.PreyPlantBonus < 0 {
+		fail("dynamics.prey_plant_bonus", "must not be negative, got %g", d.PreyPlantBonus)
+	}
+
+	// Weather
+	if c.Weather.Enabled && c.Weather.StepInterval < 1 {
+		fail("weather.step_interval", "must be at least 1 when weather is enabled, got %d", c.Weather.StepInterval)
+	}
+	if c.Weather.InitialWeather != "" && !containsString(validWeathers, c.Weather.InitialWeather) {
+		fail("weather.initial_weather", "must be one of %s, got %q", strings.Join(validWeathers, ", "), c.Weather.InitialWeather)
+	}
+
+	// Lake
+	if c.Lake.Radius < 0 {
+		fail("lake.radius", "must not be negative, got %g", c.Lake.Radius)
+	}
+	if c.Lake.MaxRadius < c.Lake.Radius {
+		fail("lake.max_radius", "must be at least radius (%g), got %g", c.Lake.Radius, c.Lake.MaxRadius)
+	}
+	if c.Lake.Center.x < 0 || c.Lake.Center.x > c.Width || c.Lake.Center.y < 0 || c.Lake.Center.y > c.Width {
+		fail("lake.center", "must lie inside the %gx%g world, got (%g, %g)", c.Width, c.Width, c.Lake.Center.x, c.Lake.Center.y)
+	}
+
+	// Other bodies of water
+	for i, w := range c.Waters {
+		field := fmt.Sprintf("waters[%d]", i)
+		switch w.Shape {
+		case "circle":
+			if w.Radius <= 0 {
+				fail(field+".radius", "must be positive, got %g", w.Radius)
+			}
+		case "polygon":
+			if len(w.Points) < 3 {
+				fail(field+".points", "a polygon needs at least 3 points, got %d", len(w.Points))
+			}
+		case "river":
+			if len(w.Points) < 2 {
+				fail(field+".points", "a river needs at least 2 points, got %d", len(w.Points))
+			}
+			if w.Width <= 0 {
+				fail(field+".width", "must be positive, got %g", w.Width)
+			}
+		default:
+			fail(field+".shape", "must be one of %s, got %q", strings.Join(validWaterShapes, ", "), w.Shape)
+		}
+	}
+	// Families and plants start on dry land, so some has to be left.
+	lake := Lake{Position: c.Lake.Center, Radius: c.Lake.Radius, MaxRadius: c.Lake.MaxRadius}
+	if c.Width > 0 && !hasDryLand(wrapWater(withLake(lake, c.waterBodies()), c.Width, c.Movement.Boundary), c.Width) {
+		fail("waters", "leave no dry land in the %gx%g world", c.Width, c.Width)
+	}
+
+	// Plants
+	if c.Plants.Count < 0 {
+		fail("plants.count", "must not be negative, got %d", c.Plants.Count)
+	}
+	if c.Plants.MinInitialSize < 0 {
+		fail("plants.min_initial_size", "must not be negative, got %g", c.Plants.MinInitialSize)
+	}
+	if c.Plants.MaxInitialSize < c.Plants.MinInitialSize {
+		fail("plants.max_initial_size", "must be at least min_initial_size (%g), got %g", c.Plants.MinInitialSize, c.Plants.MaxInitialSize)
+	}
+	if c.Plants.GrowthCoefficient < 0 {
+		fail("plants.growth_coefficient", "must not be negative, got %g", c.Plants.GrowthCoefficient)
+	}
+	if c.Plants.ConsumptionRate < 0 {
+		fail("plants.consumption_rate", "must not be negative, got %g", c.Plants.ConsumptionRate)
+	}
+	if c.Plants.ConversionFactor < 0 {
+		fail("plants.conversion_factor", "must not be negative, got %g", c.Plants.ConversionFactor)
+	}
+	if c.Plants.MaxSize <= 0 {
+		fail("plants.max_size", "must be positive, got %g", c.Plants.MaxSize)
+	}
+	if c.Plants.SeedSize < 0 {
+		fail("plants.seed_size", "must not be negative, got %g", c.Plants.SeedSize)
+	}
+	if c.Plants.RegrowthChance < 0 || c.Plants.RegrowthChance > 1 {
+		fail("plants.regrowth_chance", "must be between 0 and 1, got %g", c.Plants.RegrowthChance)
+	}
+
+	// Vegetation
+	v := c.Vegetation
+	if v.SeedingChance < 0 || v.SeedingChance > 1 {
+		fail("vegetation.seeding_chance", "must be between 0 and 1, got %g", v.SeedingChance)
+	}
+	if v.SeedRadius < 0 {
+		fail("vegetation.seed_radius", "must not be negative, got %g", v.SeedRadius)
+	}
+	if v.MinSpacing < 0 {
+		fail("vegetation.min_spacing", "must not be negative, got %g", v.MinSpacing)
+	}
+	if v.MaxPlants < 0 {
+		fail("vegetation.max_plants", "must not be negative, got %d", v.MaxPlants)
+	}
+	if v.FrostKillChance < 0 || v.FrostKillChance > 1 {
+		fail("vegetation.frost_kill_chance", "must be between 0 and 1, got %g", v.FrostKillChance)
+	}
+	if v.LakeGrowthBonus < 0 {
+		fail("vegetation.lake_growth_bonus", "must not be negative, got %g", v.LakeGrowthBonus)
+	}
+	if v.LakeInfluence < 0 {
+		fail("vegetation.lake_influence", "must not be negative, got %g", v.LakeInfluence)
+	}
+
+	// Foraging
+	f := c.Foraging
+	if f.Radius < 0 {
+		fail("foraging.radius", "must not be negative, got %g", f.Radius)
+	}
+	if f.Weight < 0 {
+		fail("foraging.weight", "must not be negative, got %g", f.Weight)
+	}
+	if f.HungerWeight < 0 {
+		fail("foraging.hunger_weight", "must not be negative, got %g", f.HungerWeight)
+	}
+	if f.HungerRate < 0 {
+		fail("foraging.hunger_rate", "must not be negative, got %g", f.HungerRate)
+	}
+	if f.Satiety < 0 {
+		fail("foraging.satiety", "must not be negative, got %g", f.Satiety)
+	}
+
+	// Energy
+	e := c.Energy
+	if e.Max <= 0 {
+		fail("energy.max", "must be positive, got %g", e.Max)
+	}
+	if e.Initial < 0 || e.Initial > e.Max {
+		fail("energy.initial", "must be between 0 and max (%g), got %g", e.Max, e.Initial)
+	}
+	if e.BaseCost < 0 {
+		fail("energy.base_cost", "must not be negative, got %g", e.BaseCost)
+	}
+	if e.SpeedCost < 0 {
+		fail("energy.speed_cost", "must not be negative, got %g", e.SpeedCost)
+	}
+	if e.SizeCost < 0 {
+		fail("energy.size_cost", "must not be negative, got %g", e.SizeCost)
+	}
+	if e.PlantEnergy < 0 {
+		fail("energy.plant_energy", "must not be negative, got %g", e.PlantEnergy)
+	}
+	if e.KillEnergy < 0 || e.KillEnergy > e.Max {
+		fail("energy.kill_energy", "must be between 0 and max (%g), got %g", e.Max, e.KillEnergy)
+	}
+	if e.StarvationThreshold < 0 {
+		fail("energy.starvation_threshold", "must not be negative, got %g", e.StarvationThreshold)
+	}
+	if e.StarvationRate < 0 {
+		fail("energy.starvation_rate", "must not be negative, got %g", e.StarvationRate)
+	}
+	if e.ReproductionRate < 0 {
+		fail("energy.reproduction_rate", "must not be negative, got %g", e.ReproductionRate)
+	}
+	if e.ReproductionCost < 0 {
+		fail("energy.reproduction_cost", "must not be negative, got %g", e.ReproductionCost)
+	}
+	if e.ReproductionThreshold < e.StarvationThreshold || e.ReproductionThreshold >= e.Max {
+		fail("energy.reproduction_threshold", "must be between starvation_threshold (%g) and max (%g), got %g", e.StarvationThreshold, e.Max, e.ReproductionThreshold)
+	}
+
+	// Hydration
+	hy := c.Hydration
+	if hy.Max <= 0 {
+		fail("hydration.max", "must be positive, got %g", hy.Max)
+	}
+	if hy.Initial < 0 || hy.Initial > hy.Max {
+		fail("hydration.initial", "must be between 0 and max (%g), got %g", hy.Max, hy.Initial)
+	}
+	if hy.LossRate < 0 {
+		fail("hydration.loss_rate", "must not be negative, got %g", hy.LossRate)
+	}
+	if hy.DrinkDistance < 0 {
+		fail("hydration.drink_distance", "must not be negative, got %g", hy.DrinkDistance)
+	}
+	if hy.DrinkRate < 0 {
+		fail("hydration.drink_rate", "must not be negative, got %g", hy.DrinkRate)
+	}
+	if hy.DehydrationThreshold <= 0 || hy.DehydrationThreshold > hy.Max {
+		fail("hydration.dehydration_threshold", "must be positive and at most max (%g), got %g", hy.Max, hy.DehydrationThreshold)
+	}
+	if hy.DehydrationRate < 0 {
+		fail("hydration.dehydration_rate", "must not be negative, got %g", hy.DehydrationRate)
+	}
+	if hy.ThirstWeight < 0 {
+		fail("hydration.thirst_weight", "must not be negative, got %g", hy.ThirstWeight)
+	}
+
+	// Terrain
+	if err := checkTerrainMap(c.Terrain.Map); err != nil {
+		fail("terrain.map", "%v", err)
+	}
+	for t := Grassland; t < numTerrainTypes; t++ {
+		field := "terrain." + t.String()
+		effect := c.Terrain.Effect(t)
+		if effect.Speed <= 0 {
+			fail(field+".speed", "must be positive, got %g", effect.Speed)
+		}
+		if effect.Growth < 0 {
+			fail(field+".growth", "must not be negative, got %g", effect.Growth)
+		}
+		if effect.Detection < 0 {
+			fail(field+".detection", "must not be negative, got %g", effect.Detection)
+		}
+	}
+
+	// Humans
+	h := c.Humans
+	if h.SettlementRadius < 0 {
+		fail("humans.settlement_radius", "must not be negative, got %g", h.SettlementRadius)
+	}
+	if h.SettlementAvoidance < 0 {
+		fail("humans.settlement_avoidance", "must not be negative, got %g", h.SettlementAvoidance)
+	}
+	if h.FarmRadius < 0 {
+		fail("humans.farm_radius", "must not be negative, got %g", h.FarmRadius)
+	}
+	if h.HomeWeight < 0 {
+		fail("humans.home_weight", "must not be negative, got %g", h.HomeWeight)
+	}
+	if h.PlantingChance < 0 || h.PlantingChance > 1 {
+		fail("humans.planting_chance", "must be between 0 and 1, got %g", h.PlantingChance)
+	}
+	if h.CropSize < 0 {
+		fail("humans.crop_size", "must not be negative, got %g", h.CropSize)
+	}
+	if h.HarvestRate < 0 {
+		fail("humans.harvest_rate", "must not be negative, got %g", h.HarvestRate)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of a string-keyed map in sorted order so that
+// validation messages (and anything iterating over species) are reproducible.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
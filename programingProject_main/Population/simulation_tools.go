@@ -6,28 +6,38 @@ import (
 )
 
 // BuildEcosystemFromConfig creates an Ecosystem instance based on a high-level
-// EcosystemConfig. It starts from InitializeEcosystem to respect any existing
-// initialization logic, then adjusts fields to match the config.
+// EcosystemConfig. The ecosystem keeps its own copy of the config, and
+// UpdateEcosystem reads every simulation parameter from it.
 func BuildEcosystemFromConfig(cfg EcosystemConfig) Ecosystem {
-	eco := InitializeEcosystem()
-
-	// Width and lake parameters
-	eco.width = cfg.Width
-	eco.Lake.Position = cfg.Lake.Center
-	eco.Lake.Radius = cfg.Lake.Radius
-	eco.Lake.MaxRadius = cfg.Lake.MaxRadius
+	localCfg := cfg.Clone()
+	if localCfg.Seed == 0 {
+		// Record the seed that was picked so the run can be repeated.
+		localCfg.Seed = NewRandomSeed()
+	}
+	rng, rngSource := newRandomSource(localCfg.Seed)
 
-	// Weather settings
-	if cfg.Weather.InitialWeather != "" {
-		eco.weather = cfg.Weather.InitialWeather
+	// Initialize the lake *before* creating families and plants that need to check its position.
+	lake := Lake{
+		Position:  localCfg.Lake.Center,
+		Radius:    localCfg.Lake.Radius,
+		MaxRadius: localCfg.Lake.MaxRadius,
 	}
 
-	// Carrying capacity override from config, if non-empty
-	if len(cfg.Population.CarryingCapacities) > 0 {
-		eco.CarryingCapacity = make(map[string]int)
-		for k, v := range cfg.Population.CarryingCapacities {
-			eco.CarryingCapacity[k] = v
-		}
+	water := wrapWater(withLake(lake, localCfg.waterBodies()), localCfg.Width, localCfg.Movement.Boundary)
+
+	eco := Ecosystem{
+		Families:         initializeFamilies(&localCfg, water, rng),
+		Plants:           initializePlants(&localCfg, water, rng),
+		width:            localCfg.Width,
+		weather:          localCfg.Weather.InitialWeather,
+		Lake:             lake,
+		CarryingCapacity: make(map[string]int),
+		config:           &localCfg,
+		rng:              rng,
+		rngSource:        rngSource,
+	}
+	for k, v := range localCfg.Population.CarryingCapacities {
+		eco.CarryingCapacity[k] = v
 	}
 
 	return eco
@@ -43,11 +53,6 @@ func RunConfiguredSimulation(cfg EcosystemConfig, numSteps int) EcosystemStateSe
 	eco := BuildEcosystemFromConfig(localCfg)
 	series := EcosystemStateSeries{}
 
-	// Optionally adjust the lake according to initial weather.
-	if localCfg.Weather.Enabled {
-		eco.weather = localCfg.Weather.InitialWeather
-	}
-
 	for step := 0; step < numSteps; step++ {
 		// Record current state.
 		snap := NewPopulationSnapshot(step, &eco)
@@ -69,7 +74,8 @@ func RunConfiguredSimulation(cfg EcosystemConfig, numSteps int) EcosystemStateSe
 }
 
 // ComputeAveragePairwiseDistance uses the geometry helpers to estimate the
-// average distance between families (based on their positions).
+// average distance between families (based on their positions). Distances
+// follow the boundary of the world, so on a torus they cross its edges.
 func ComputeAveragePairwiseDistance(eco *Ecosystem) float64 {
 	n := len(eco.Families)
 	if n < 2 {
@@ -82,7 +88,7 @@ func ComputeAveragePairwiseDistance(eco *Ecosystem) float64 {
 		for j := i + 1; j < n; j++ {
 			a := eco.Families[i].Position
 			b := eco.Families[j].Position
-			d := DistanceOrdered(a, b)
+			d := eco.distanceBetween(a, b)
 			sum += d
 			count++
 		}
@@ -198,7 +204,7 @@ func ExampleConfigVariants(base EcosystemConfig) []EcosystemConfig {
 	// Variant 2: faster movement.
 	v2 := base.Clone()
 	v2.Movement.MaxSpeed *= 1.3
-	v2.Movement.TimeStep = math.Max(0.1, v2.Movement.TimeStep*0.8)
+	v2.Movement.TimeStep = math.Max(0.01, v2.Movement.TimeStep*0.8)
 	variants = append(variants, v2)
 
 	// Variant 3: higher predator capacity.
@@ -207,7 +213,7 @@ func ExampleConfigVariants(base EcosystemConfig) []EcosystemConfig {
 		v3.Population.CarryingCapacities = make(map[string]int)
 	}
 	for name, capVal := range v3.Population.CarryingCapacities {
-		if SpeciesRegistry[name].Type == "predator" {
+		if v3.Species[name].Type == "predator" {
 			v3.Population.CarryingCapacities[name] = int(float64(capVal) * 1.5)
 		}
 	}
@@ -0,0 +1,156 @@
+package main
+
+import "math"
+
+// A spatialGrid buckets points into square cells that tile the wrapping world,
+// so a neighbour query only looks at the cells around a point instead of at
+// every family or plant. Grids are rebuilt from scratch every step; that is a
+// single pass over the points and much simpler than tracking moves, splits
+// and merges.
+
+// maxGridCells caps the number of cells per side so a tiny query radius in a
+// large world does not allocate millions of empty cells.
+const maxGridCells = 512
+
+type spatialGrid struct {
+	width    float64
+	cellSize float64
+	cells    int   // cells per side
+	points   int   // number of indexed points
+	start    []int // the points of cell c are items[start[c]:start[c+1]]
+	items    []int
+}
+
+// newSpatialGrid indexes the positions with cells at least cellSize wide.
+// Positions outside [0, width) are wrapped into the world first.
+func newSpatialGrid(positions []OrderedPair, width, cellSize float64) *spatialGrid {
+	cells := 1
+	if width > 0 && cellSize > 0 {
+		cells = int(width / cellSize)
+	}
+	cells = max(1, min(cells, maxGridCells))
+	g := &spatialGrid{
+		width:    width,
+		cellSize: width / float64(cells),
+		cells:    cells,
+		points:   len(positions),
+		start:    make([]int, cells*cells+1),
+		items:    make([]int, len(positions)),
+	}
+
+	// Counting sort by cell.
+	cellOf := make([]int, len(positions))
+	for i, p := range positions {
+		cellOf[i] = g.cell(g.column(p.x), g.column(p.y))
+		g.start[cellOf[i]+1]++
+	}
+	for c := 1; c < len(g.start); c++ {
+		g.start[c] += g.start[c-1]
+	}
+	next := append([]int(nil), g.start[:len(g.start)-1]...)
+	for i, c := range cellOf {
+		g.items[next[c]] = i
+		next[c]++
+	}
+	return g
+}
+
+// column returns the cell column (or row) of a coordinate, wrapping it into the world.
+func (g *spatialGrid) column(v float64) int {
+	if g.width <= 0 {
+		return 0
+	}
+	v = math.Mod(v, g.width)
+	if v < 0 {
+		v += g.width
+	}
+	if !(v >= 0) { // NaN
+		return 0
+	}
+	return min(int(v/g.cellSize), g.cells-1)
+}
+
+func (g *spatialGrid) cell(col, row int) int {
+	return row*g.cells + col
+}
+
+// near returns buf refilled with the indices of every point that may lie within
+// radius of p, counting distances across the world edges. The order depends
+// only on the positions, so runs stay reproducible. The result can include
+// points that are further away; callers still check the distance.
+func (g *spatialGrid) near(p OrderedPair, radius float64, buf []int) []int {
+	buf = buf[:0]
+	reach := g.cells
+	if radius >= 0 && g.cellSize > 0 {
+		reach = int(math.Ceil(radius / g.cellSize))
+	}
+	if 2*reach+1 >= g.cells {
+		// The query covers every column and row; visiting each cell once is enough.
+		for i := 0; i < g.points; i++ {
+			buf = append(buf, i)
+		}
+		return buf
+	}
+
+	col, row := g.column(p.x), g.column(p.y)
+	for dy := -reach; dy <= reach; dy++ {
+		r := (row + dy + g.cells) % g.cells
+		for dx := -reach; dx <= reach; dx++ {
+			c := g.cell((col+dx+g.cells)%g.cells, r)
+			buf = append(buf, g.items[g.start[c]:g.start[c+1]]...)
+		}
+	}
+	return buf
+}
+
+func familyPositions(families []Family) []OrderedPair {
+	positions := make([]OrderedPair, len(families))
+	for i, f := range families {
+		positions[i] = f.Position
+	}
+	return positions
+}
+
+func plantPositions(plants []Plant) []OrderedPair {
+	positions := make([]OrderedPair, len(plants))
+	for i, p := range plants {
+		positions[i] = p.position
+	}
+	return positions
+}
+
+// indexFamilies rebuilds the family grid used by the movement forces.
+// UpdateEcosystem drops the grid again as soon as the families have moved.
+func (e *Ecosystem) indexFamilies(cellSize float64) {
+	e.familyGrid = newSpatialGrid(familyPositions(e.Families), e.width, cellSize)
+}
+
+// indexPlants rebuilds the plant grid used by the foraging force.
+func (e *Ecosystem) indexPlants(cellSize float64) {
+	e.plantGrid = newSpatialGrid(plantPositions(e.Plants), e.width, cellSize)
+}
+
+// familiesNear returns the indices of the families that may lie within radius
+// of p. Without a grid (outside of UpdateEcosystem) every family is returned.
+func (e *Ecosystem) familiesNear(p OrderedPair, radius float64, buf []int) []int {
+	if e.familyGrid != nil && e.familyGrid.points == len(e.Families) {
+		return e.familyGrid.near(p, radius, buf)
+	}
+	buf = buf[:0]
+	for i := range e.Families {
+		buf = append(buf, i)
+	}
+	return buf
+}
+
+// plantsNear is familiesNear for plants.
+func (e *Ecosystem) plantsNear(p OrderedPair, radius float64, buf []int) []int {
+	if e.plantGrid != nil && e.plantGrid.points == len(e.Plants) {
+		return e.plantGrid.near(p, radius, buf)
+	}
+	buf = buf[:0]
+	for i := range e.Plants {
+		buf = append(buf, i)
+	}
+	return buf
+}
@@ -1,5 +1,7 @@
 package main
 
+import "math/rand/v2"
+
 type PopulationSnapshot struct {
 	Step            int
 	SpeciesCounts   map[string]int
@@ -59,3 +61,29 @@ func (s *EcosystemStateSeries) TotalPopulationTrajectory() []int {
 	}
 	return values
 }
+
+// Clone returns a deep copy of the ecosystem. Families, plants, the settlement
+// and the carrying capacity map are copied, and the copy continues the same random stream
+// independently, so updating either ecosystem never changes the other.
+// The config is shared because it is never modified during a run.
+func (e Ecosystem) Clone() Ecosystem {
+	c := e
+	c.Families = append([]Family(nil), e.Families...)
+	c.Plants = append([]Plant(nil), e.Plants...)
+	if e.CarryingCapacity != nil {
+		c.CarryingCapacity = make(map[string]int, len(e.CarryingCapacity))
+		for k, v := range e.CarryingCapacity {
+			c.CarryingCapacity[k] = v
+		}
+	}
+	if e.settlement != nil {
+		s := *e.settlement
+		c.settlement = &s
+	}
+	if e.rngSource != nil {
+		src := *e.rngSource
+		c.rngSource = &src
+		c.rng = rand.New(c.rngSource)
+	}
+	return c
+}
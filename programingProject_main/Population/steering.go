@@ -0,0 +1,139 @@
+package main
+
+import "math"
+
+// Steering forces make predators chase, prey run and herds stay together.
+// Who hunts and who flees from whom comes from the interaction matrix (see
+// interactions.go). Each species only reacts to families within its
+// PerceptionRadius; predators see prey in forest from closer and prey on open
+// ground from further away (see terrain.go). On a torus families see each
+// other across the edges of the world (see boundary.go). Every force has at
+// most unit strength and is scaled by its weight in UpdateAcceleration.
+
+// PursuitForce returns the unit vector from a family toward the nearest
+// family it can perceive and eats, or zero when it sees none. A family is
+// perceived within PerceptionRadius times the detection factor of the terrain
+// it stands on.
+func PursuitForce(ecosystem *Ecosystem, i int) OrderedPair {
+	hunter := ecosystem.Families[i]
+	radius := hunter.species.PerceptionRadius
+	cfg := ecosystem.settings()
+	interactions := cfg.Interactions
+	if len(interactions[hunter.species.Name]) == 0 || radius <= 0 {
+		return OrderedPair{}
+	}
+
+	nearest := -1
+	nearestDist := math.Inf(1)
+	for _, j := range ecosystem.familiesNear(hunter.Position, radius*cfg.Terrain.maxDetection(), nil) {
+		other := ecosystem.Families[j]
+		if j == i || interactions.Between(hunter.species.Name, other.species.Name).Predation <= 0 {
+			continue
+		}
+		d := ecosystem.distanceBetween(hunter.Position, other.Position)
+		if d <= radius*cfg.Terrain.EffectAt(other.Position, ecosystem.width).Detection && d < nearestDist {
+			nearest, nearestDist = j, d
+		}
+	}
+	if nearest < 0 {
+		return OrderedPair{}
+	}
+	return NormalizeOrdered(ecosystem.offset(hunter.Position, ecosystem.Families[nearest].Position))
+}
+
+// EvasionForce returns the direction a family flees in: away from every
+// family it can perceive and avoids, with closer families and a higher
+// avoidance weight counting more. The result is zero when nothing it avoids
+// is in sight.
+func EvasionForce(ecosystem *Ecosystem, i int) OrderedPair {
+	prey := ecosystem.Families[i]
+	radius := prey.species.PerceptionRadius
+	interactions := ecosystem.settings().Interactions
+	if len(interactions[prey.species.Name]) == 0 || radius <= 0 {
+		return OrderedPair{}
+	}
+
+	var away OrderedPair
+	for _, j := range ecosystem.familiesNear(prey.Position, radius, nil) {
+		other := ecosystem.Families[j]
+		avoidance := interactions.Between(prey.species.Name, other.species.Name).Avoidance
+		if j == i || avoidance <= 0 {
+			continue
+		}
+		fromOther := ecosystem.offset(other.Position, prey.Position)
+		d := NormOrdered(fromOther)
+		if d > radius || d == 0 {
+			continue
+		}
+		// A family at the edge of sight barely matters; one right next to the prey counts fully.
+		urgency := avoidance * (1 - d/radius)
+		away = AddOrdered(away, ScaleOrdered(NormalizeOrdered(fromOther), urgency))
+	}
+	if NormOrdered(away) > 1 {
+		return NormalizeOrdered(away)
+	}
+	return away
+}
+
+// Flocking (Boids) keeps families of the same species together: cohesion pulls
+// a family toward the centre of its neighbours and alignment turns it toward
+// their average velocity. Neighbours are the same-species families within the
+// family's PerceptionRadius; the weights are set per species.
+
+// CohesionForce points from the family toward the centre of its same-species
+// neighbours, scaled so a centre at the edge of sight has unit strength.
+func CohesionForce(ecosystem *Ecosystem, i int) OrderedPair {
+	f := ecosystem.Families[i]
+	radius := f.species.PerceptionRadius
+	if radius <= 0 {
+		return OrderedPair{}
+	}
+
+	var sum OrderedPair
+	n := 0.0
+	for _, j := range ecosystem.familiesNear(f.Position, radius, nil) {
+		other := ecosystem.Families[j]
+		toOther := ecosystem.offset(f.Position, other.Position)
+		if j == i || other.species.Name != f.species.Name || NormOrdered(toOther) > radius {
+			continue
+		}
+		sum = AddOrdered(sum, toOther)
+		n++
+	}
+	if n == 0 {
+		return OrderedPair{}
+	}
+	// The centre is averaged from the offsets, so neighbours across an edge of the world pull the right way.
+	return ScaleOrdered(sum, 1/(n*radius))
+}
+
+// AlignmentForce points from the family's velocity toward the average velocity
+// of its same-species neighbours, relative to the maximum speed and capped at
+// unit strength.
+func AlignmentForce(ecosystem *Ecosystem, i int) OrderedPair {
+	f := ecosystem.Families[i]
+	radius := f.species.PerceptionRadius
+	maxSpeed := ecosystem.settings().Movement.MaxSpeed
+	if radius <= 0 || maxSpeed <= 0 {
+		return OrderedPair{}
+	}
+
+	var sum OrderedPair
+	n := 0.0
+	for _, j := range ecosystem.familiesNear(f.Position, radius, nil) {
+		other := ecosystem.Families[j]
+		if j == i || other.species.Name != f.species.Name || ecosystem.distanceBetween(f.Position, other.Position) > radius {
+			continue
+		}
+		sum = AddOrdered(sum, other.MovementSpeed)
+		n++
+	}
+	if n == 0 {
+		return OrderedPair{}
+	}
+	steer := ScaleOrdered(SubOrdered(ScaleOrdered(sum, 1/n), f.MovementSpeed), 1/maxSpeed)
+	if NormOrdered(steer) > 1 {
+		return NormalizeOrdered(steer)
+	}
+	return steer
+}
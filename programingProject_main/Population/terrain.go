@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The world can be covered by a terrain map: a raster of grassland, forest,
+// rock and marsh cells stretched over the whole width of the world. Terrain
+// slows families down (see UpdateEcosystem), makes plants grow faster or
+// slower (see UpdateVegetation) and hides prey from predators (see
+// PursuitForce). The map is kept in the config, one letter per cell, so
+// scenarios and checkpoints carry it along; it is read from a PNG or CSV file
+// with LoadTerrainMap. Without a map the whole world is grassland.
+
+// TerrainType is the kind of ground in one cell of the terrain map.
+type TerrainType int
+
+const (
+	Grassland TerrainType = iota
+	Forest
+	Rock
+	Marsh
+	numTerrainTypes
+)
+
+// terrainNames are the names of the terrain types in CSV files; the first
+// letter of each name is the letter used in TerrainConfig.Map.
+var terrainNames = [numTerrainTypes]string{"grassland", "forest", "rock", "marsh"}
+
+// terrainColors are the colours of the terrain types in PNG files and on the canvas.
+var terrainColors = [numTerrainTypes]Color{
+	{R: 140, G: 200, B: 90, A: 255},  // grassland
+	{R: 30, G: 110, B: 40, A: 255},   // forest
+	{R: 130, G: 130, B: 130, A: 255}, // rock
+	{R: 80, G: 120, B: 110, A: 255},  // marsh
+}
+
+func (t TerrainType) String() string {
+	return terrainNames[t]
+}
+
+// letter is the character that stands for the terrain type in TerrainConfig.Map.
+func (t TerrainType) letter() byte {
+	return terrainNames[t][0]
+}
+
+// terrainFromLetter returns the terrain type a map letter stands for.
+func terrainFromLetter(b byte) (TerrainType, bool) {
+	for t := Grassland; t < numTerrainTypes; t++ {
+		if t.letter() == b {
+			return t, true
+		}
+	}
+	return Grassland, false
+}
+
+// At returns the terrain at a position in a world of the given width.
+// Positions outside the world are wrapped into it.
+func (c TerrainConfig) At(p OrderedPair, width float64) TerrainType {
+	rows := len(c.Map)
+	if rows == 0 || width <= 0 {
+		return Grassland
+	}
+	cols := len(c.Map[0])
+	col := terrainCell(p.x, width, cols)
+	row := terrainCell(p.y, width, rows)
+	t, _ := terrainFromLetter(c.Map[row][col])
+	return t
+}
+
+// terrainCell returns the cell a coordinate falls in when width is split into n cells.
+func terrainCell(v, width float64, n int) int {
+	v = math.Mod(v, width)
+	if v < 0 {
+		v += width
+	}
+	return min(n-1, int(v/width*float64(n)))
+}
+
+// Effect returns how the terrain type changes movement, plant growth and detection.
+func (c TerrainConfig) Effect(t TerrainType) TerrainEffect {
+	switch t {
+	case Forest:
+		return c.Forest
+	case Rock:
+		return c.Rock
+	case Marsh:
+		return c.Marsh
+	default:
+		return c.Grassland
+	}
+}
+
+// EffectAt returns the effect of the terrain at a position.
+func (c TerrainConfig) EffectAt(p OrderedPair, width float64) TerrainEffect {
+	return c.Effect(c.At(p, width))
+}
+
+// maxDetection is the largest detection factor of any terrain type, so
+// neighbour queries can be sized for the most visible families.
+func (c TerrainConfig) maxDetection() float64 {
+	m := 0.0
+	for t := Grassland; t < numTerrainTypes; t++ {
+		m = math.Max(m, c.Effect(t).Detection)
+	}
+	return m
+}
+
+// LoadTerrainMap reads a terrain map from a PNG or CSV file, chosen by the
+// file extension, and returns it in the form of TerrainConfig.Map.
+func LoadTerrainMap(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rows []string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".png":
+		rows, err = ReadTerrainPNG(file)
+	case ".csv":
+		rows, err = ReadTerrainCSV(file)
+	default:
+		return nil, fmt.Errorf("terrain map %s: unsupported format %q (use png or csv)", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("terrain map %s: %w", path, err)
+	}
+	return rows, nil
+}
+
+// ReadTerrainCSV reads a terrain map with one row of cells per line. A cell
+// holds a terrain name, its first letter or its number (0 grassland, 1 forest,
+// 2 rock, 3 marsh).
+func ReadTerrainCSV(r io.Reader) ([]string, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]string, len(records))
+	for i, record := range records {
+		row := make([]byte, len(record))
+		for j, cell := range record {
+			t, ok := parseTerrainCell(strings.ToLower(strings.TrimSpace(cell)))
+			if !ok {
+				return nil, fmt.Errorf("row %d, column %d: unknown terrain %q", i+1, j+1, cell)
+			}
+			row[j] = t.letter()
+		}
+		rows[i] = string(row)
+	}
+	if err := checkTerrainMap(rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func parseTerrainCell(cell string) (TerrainType, bool) {
+	if n, err := strconv.Atoi(cell); err == nil {
+		return TerrainType(n), n >= 0 && n < int(numTerrainTypes)
+	}
+	for t := Grassland; t < numTerrainTypes; t++ {
+		if cell == t.String() || (len(cell) == 1 && cell[0] == t.letter()) {
+			return t, true
+		}
+	}
+	return Grassland, false
+}
+
+// ReadTerrainPNG reads a terrain map with one cell per pixel. Every pixel
+// becomes the terrain type whose colour (see terrainColors) is closest to it.
+func ReadTerrainPNG(r io.Reader) ([]string, error) {
+	img, err := png.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	rows := make([]string, bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := make([]byte, bounds.Dx())
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			row[x-bounds.Min.X] = nearestTerrain(img.At(x, y)).letter()
+		}
+		rows[y-bounds.Min.Y] = string(row)
+	}
+	if err := checkTerrainMap(rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// nearestTerrain returns the terrain type whose colour is closest to c.
+func nearestTerrain(c color.Color) TerrainType {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	best, bestDist := Grassland, math.Inf(1)
+	for t := Grassland; t < numTerrainTypes; t++ {
+		tc := terrainColors[t]
+		dr := float64(rgba.R) - float64(tc.R)
+		dg := float64(rgba.G) - float64(tc.G)
+		db := float64(rgba.B) - float64(tc.B)
+		if d := dr*dr + dg*dg + db*db; d < bestDist {
+			best, bestDist = t, d
+		}
+	}
+	return best
+}
+
+// WriteTerrainPNG writes a terrain map as a PNG with one pixel per cell,
+// which ReadTerrainPNG reads back unchanged.
+func WriteTerrainPNG(w io.Writer, rows []string) error {
+	if err := checkTerrainMap(rows); err != nil {
+		return err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x := 0; x < len(row); x++ {
+			t, _ := terrainFromLetter(row[x])
+			c := terrainColors[t]
+			img.SetRGBA(x, y, color.RGBA{R: c.R, G: c.G, B: c.B, A: c.A})
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// checkTerrainMap reports a map that is not a rectangle of known letters.
+// An empty map is valid and means the world is all grassland.
+func checkTerrainMap(rows []string) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	cols := len(rows[0])
+	if cols == 0 {
+		return fmt.Errorf("the map has empty rows")
+	}
+	for i, row := range rows {
+		if len(row) != cols {
+			return fmt.Errorf("row %d has %d cells, the first row has %d", i+1, len(row), cols)
+		}
+		for j := 0; j < len(row); j++ {
+			if _, ok := terrainFromLetter(row[j]); !ok {
+				return fmt.Errorf("row %d, column %d: unknown terrain letter %q", i+1, j+1, row[j])
+			}
+		}
+	}
+	return nil
+}
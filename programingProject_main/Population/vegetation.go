@@ -0,0 +1,115 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// Plants are agents that spread: a well-grown plant now and then drops a seed
+// near itself, which either starts a new plant or revives a dead one where it
+// lands. Frost kills plants in Frozen weather, and plants close to the water
+// grow faster than plants far away from it. The terrain a plant stands on
+// speeds up or slows down its growth (see terrain.go).
+
+// UpdateVegetation advances all plants by one step: frost first, then growth
+// (see PlantGrowth) with the lake and terrain bonus, then seeding.
+func UpdateVegetation(ecosystem *Ecosystem) {
+	cfg := ecosystem.settings()
+	rng := ecosystem.random()
+	weatherCoeff := 1.0 + CoefficientOfPlantIncrease(ecosystem.weather)
+
+	if ecosystem.weather == "Frozen" {
+		for i := range ecosystem.Plants {
+			if ecosystem.Plants[i].size > 0 && rng.Float64() < cfg.Vegetation.FrostKillChance {
+				ecosystem.Plants[i].size = 0
+			}
+		}
+	}
+
+	water := ecosystem.water()
+	for i := range ecosystem.Plants {
+		p := &ecosystem.Plants[i]
+		rateCoeff := weatherCoeff * LakeGrowthFactor(p.position, water, cfg.Vegetation) *
+			cfg.Terrain.EffectAt(p.position, ecosystem.width).Growth
+		growPlant(p, cfg.Plants, rateCoeff, weatherCoeff, rng)
+	}
+
+	ecosystem.Plants = SpreadSeeds(ecosystem.Plants, ecosystem.width, ecosystem.boundary(), water, cfg.Plants, cfg.Vegetation, rng)
+}
+
+// LakeGrowthFactor multiplies the growth rate of a plant at the given position:
+// 1+LakeGrowthBonus at the shore of the lake or any other water (or in the
+// water), falling linearly to 1 at LakeInfluence from the shore.
+func LakeGrowthFactor(position OrderedPair, water WaterBody, cfg VegetationConfig) float64 {
+	if cfg.LakeInfluence <= 0 {
+		return 1
+	}
+	shore := ShoreDistance(position, water)
+	return 1 + cfg.LakeGrowthBonus*math.Max(0, 1-shore/cfg.LakeInfluence)
+}
+
+// SpreadSeeds lets every plant that has reached half its maximum drop a seed
+// with probability SeedingChance. The seed lands between MinSpacing and
+// SeedRadius away; beyond an edge of the world it fares like a family that
+// crosses it (see ApplyBoundary), so it is wrapped around, bounced back in or
+// lost. In the water it is lost too; next to a dead plant it revives that
+// plant; next to a living plant it is crowded out; anywhere else it starts a
+// new plant, as long as there are fewer than MaxPlants plants.
+func SpreadSeeds(plants []Plant, width float64, boundary string, water WaterBody, plantCfg PlantConfig, cfg VegetationConfig, rng *rand.Rand) []Plant {
+	if cfg.SeedingChance <= 0 || width <= 0 {
+		return plants
+	}
+	grid := newSpatialGrid(plantPositions(plants), width, cfg.MinSpacing)
+	var nearby []int
+	parents := len(plants)
+
+	for i := 0; i < parents; i++ {
+		parent := plants[i]
+		capacity := parent.maxSize
+		if capacity <= 0 {
+			capacity = plantCfg.MaxSize
+		}
+		if parent.size <= 0 || parent.size < capacity/2 || rng.Float64() >= cfg.SeedingChance {
+			continue
+		}
+
+		angle := rng.Float64() * 2 * math.Pi
+		reach := cfg.MinSpacing + rng.Float64()*math.Max(0, cfg.SeedRadius-cfg.MinSpacing)
+		spot, _, inWorld := ApplyBoundary(OrderedPair{
+			x: parent.position.x + reach*math.Cos(angle),
+			y: parent.position.y + reach*math.Sin(angle),
+		}, OrderedPair{}, width, boundary)
+		if !inWorld || water.Contains(spot) {
+			continue
+		}
+
+		// Plants grown from earlier seeds this step are not in the grid yet.
+		crowded, dead := false, -1
+		nearby = grid.near(spot, cfg.MinSpacing, nearby)
+		for j := parents; j < len(plants); j++ {
+			nearby = append(nearby, j)
+		}
+		for _, j := range nearby {
+			// On a torus seeds land across the edges of the world, so crowding is measured across them too.
+			if NormOrdered(Offset(spot, plants[j].position, width, boundary)) >= cfg.MinSpacing {
+				continue
+			}
+			if plants[j].size > 0 {
+				crowded = true
+				break
+			}
+			if dead < 0 {
+				dead = j
+			}
+		}
+		switch {
+		case crowded:
+			// The seed does not take root.
+		case dead >= 0:
+			plants[dead].size = plantCfg.SeedSize
+		case len(plants) < cfg.MaxPlants:
+			plants = append(plants, Plant{position: spot, size: plantCfg.SeedSize, maxSize: parent.maxSize})
+		}
+	}
+	return plants
+}
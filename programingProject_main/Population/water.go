@@ -0,0 +1,373 @@
+package main
+
+import "math"
+
+// Besides the lake the world can hold any number of ponds, polygonal lakes
+// and rivers, set in EcosystemConfig.Waters. Every body of water is a
+// WaterBody: families cannot enter it and bounce off its shore, seeds that
+// land on it are lost and plants near its shore grow faster. Only the lake
+// rises and falls with the weather; the other bodies keep their shape.
+
+// WaterBody is a patch of water of any shape.
+type WaterBody interface {
+	// Contains reports whether the point lies in the water.
+	Contains(p OrderedPair) bool
+	// Shore returns the point of the shore nearest to p and the unit normal
+	// there, pointing out of the water.
+	Shore(p OrderedPair) (point, normal OrderedPair)
+}
+
+// Waters is all the water in the world. It is a WaterBody itself: a point is
+// in it when it is in any of the bodies.
+type Waters []WaterBody
+
+// Polygon is a lake bounded by straight edges between its corners.
+type Polygon struct {
+	Points []OrderedPair
+}
+
+// River is a band of water of the given width along a line through Points.
+type River struct {
+	Points []OrderedPair
+	Width  float64
+}
+
+// shoreBuffer is how far outside the shore a family is put when it is pushed
+// out of the water, as in PushOutOfLake.
+const shoreBuffer = 1.0
+
+// dryLandSamples is the number of points per side of the grid that hasDryLand
+// looks at.
+const dryLandSamples = 100
+
+// waterSet is the water of the world: the bodies as configured and the same
+// bodies wrapped around a torus (see wrapWater). The wrapped water tells what
+// is in the water; distances to the shore are measured from the images of a
+// point to the bodies (see ShoreOffset), so the water is not wrapped twice.
+type waterSet struct {
+	bodies  Waters
+	wrapped Waters
+}
+
+// waters returns the water of the ecosystem: the water of the current step
+// during UpdateEcosystem, and otherwise the water as it is now.
+func (e *Ecosystem) waters() *waterSet {
+	if e.stepWater != nil {
+		return e.stepWater
+	}
+	bodies := withLake(e.Lake, e.settings().waterBodies())
+	return &waterSet{bodies: bodies, wrapped: wrapWater(bodies, e.width, e.boundary())}
+}
+
+// indexWater fixes the water for the rest of the step, once the lake has
+// taken its size for the weather. UpdateEcosystem drops it at the end of the step.
+func (e *Ecosystem) indexWater() {
+	e.stepWater = nil
+	e.stepWater = e.waters()
+}
+
+// water returns the lake and the other bodies of water of the ecosystem, with
+// their copies across the edges on a torus (see wrapWater).
+func (e *Ecosystem) water() Waters {
+	return e.waters().wrapped
+}
+
+// bodiesOfWater returns the lake and the other bodies of water of the
+// ecosystem without their copies, for ShoreOffset.
+func (e *Ecosystem) bodiesOfWater() Waters {
+	return e.waters().bodies
+}
+
+// withLake returns the lake followed by the other bodies. A lake without a
+// radius is no water at all and is left out.
+func withLake(lake Lake, bodies Waters) Waters {
+	if lake.Radius <= 0 {
+		return bodies
+	}
+	return append(Waters{lake}, bodies...)
+}
+
+// wrapWater returns the water of a world of the given width and boundary. On a
+// torus a body that sticks out over an edge comes back on the other side, so
+// it is joined by its copies one world width away (see torusImages): families
+// and seeds keep off the water on both sides, and it is drawn on both.
+func wrapWater(water Waters, width float64, boundary string) Waters {
+	if (boundary != BoundaryTorus && boundary != "") || width <= 0 {
+		return water
+	}
+	wrapped := make(Waters, 0, len(water))
+	for _, body := range water {
+		centre, radius := waterCircle(body)
+		for _, p := range torusImages(centre, width, radius) {
+			wrapped = append(wrapped, shiftWater(body, SubOrdered(p, centre)))
+		}
+	}
+	return wrapped
+}
+
+// waterCircle returns a circle that holds the whole body of water.
+func waterCircle(body WaterBody) (centre OrderedPair, radius float64) {
+	var points []OrderedPair
+	switch w := body.(type) {
+	case Lake:
+		return w.Position, w.Radius
+	case Polygon:
+		points = w.Points
+	case River:
+		points, radius = w.Points, w.Width/2
+	}
+	if len(points) == 0 {
+		return OrderedPair{}, 0
+	}
+	for _, p := range points {
+		centre = AddOrdered(centre, p)
+	}
+	centre = ScaleOrdered(centre, 1/float64(len(points)))
+	far := 0.0
+	for _, p := range points {
+		far = math.Max(far, distance(centre, p))
+	}
+	return centre, far + radius
+}
+
+// shiftWater returns the body of water moved by d.
+func shiftWater(body WaterBody, d OrderedPair) WaterBody {
+	shift := func(points []OrderedPair) []OrderedPair {
+		moved := make([]OrderedPair, len(points))
+		for i, p := range points {
+			moved[i] = AddOrdered(p, d)
+		}
+		return moved
+	}
+	switch w := body.(type) {
+	case Lake:
+		w.Position = AddOrdered(w.Position, d)
+		return w
+	case Polygon:
+		return Polygon{Points: shift(w.Points)}
+	case River:
+		return River{Points: shift(w.Points), Width: w.Width}
+	}
+	return body
+}
+
+// waterBodies returns the bodies of water described in the config.
+func (c *EcosystemConfig) waterBodies() Waters {
+	bodies := make(Waters, 0, len(c.Waters))
+	for _, w := range c.Waters {
+		switch w.Shape {
+		case "circle":
+			bodies = append(bodies, Lake{Position: w.Center, Radius: w.Radius, MaxRadius: w.Radius})
+		case "polygon":
+			bodies = append(bodies, Polygon{Points: w.Points})
+		case "river":
+			bodies = append(bodies, River{Points: w.Points, Width: w.Width})
+		}
+	}
+	return bodies
+}
+
+func (l Lake) Contains(p OrderedPair) bool {
+	return IsInLake(p, l)
+}
+
+func (l Lake) Shore(p OrderedPair) (point, normal OrderedPair) {
+	normal = NormalizeOrdered(SubOrdered(p, l.Position))
+	if normal == (OrderedPair{}) {
+		normal = OrderedPair{x: 1}
+	}
+	return AddOrdered(l.Position, ScaleOrdered(normal, l.Radius)), normal
+}
+
+// Contains counts the edges a ray from p to the right crosses: an odd number
+// means p is inside.
+func (poly Polygon) Contains(p OrderedPair) bool {
+	inside := false
+	n := len(poly.Points)
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		a, b := poly.Points[i], poly.Points[j]
+		if (a.y > p.y) != (b.y > p.y) && p.x < (b.x-a.x)*(p.y-a.y)/(b.y-a.y)+a.x {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func (poly Polygon) Shore(p OrderedPair) (point, normal OrderedPair) {
+	point, edge := nearestOnPath(p, poly.Points, true)
+	normal = NormalizeOrdered(SubOrdered(p, point))
+	if normal == (OrderedPair{}) {
+		// p lies on the shore: use the edge's perpendicular and check which side is dry.
+		normal = NormalizeOrdered(OrderedPair{x: edge.y, y: -edge.x})
+		if poly.Contains(AddOrdered(point, normal)) {
+			normal = ScaleOrdered(normal, -1)
+		}
+		return point, normal
+	}
+	if poly.Contains(p) {
+		normal = ScaleOrdered(normal, -1)
+	}
+	return point, normal
+}
+
+func (r River) Contains(p OrderedPair) bool {
+	if len(r.Points) == 0 {
+		return false
+	}
+	centre, _ := nearestOnPath(p, r.Points, false)
+	return distance(p, centre) <= r.Width/2
+}
+
+func (r River) Shore(p OrderedPair) (point, normal OrderedPair) {
+	centre, segment := nearestOnPath(p, r.Points, false)
+	normal = NormalizeOrdered(SubOrdered(p, centre))
+	if normal == (OrderedPair{}) {
+		normal = NormalizeOrdered(OrderedPair{x: -segment.y, y: segment.x})
+		if normal == (OrderedPair{}) {
+			normal = OrderedPair{x: 1}
+		}
+	}
+	return AddOrdered(centre, ScaleOrdered(normal, r.Width/2)), normal
+}
+
+// nearestOnPath returns the point of the line through points (closed back to
+// the first point when closed is true) that is nearest to p, and the
+// direction of the segment it lies on.
+func nearestOnPath(p OrderedPair, points []OrderedPair, closed bool) (nearest, direction OrderedPair) {
+	if len(points) == 1 {
+		return points[0], OrderedPair{}
+	}
+	segments := len(points) - 1
+	if closed {
+		segments = len(points)
+	}
+	best := math.Inf(1)
+	for i := 0; i < segments; i++ {
+		a, b := points[i], points[(i+1)%len(points)]
+		ab := SubOrdered(b, a)
+		t := 0.0
+		if l2 := ab.x*ab.x + ab.y*ab.y; l2 > 0 {
+			t = math.Max(0, math.Min(1, ((p.x-a.x)*ab.x+(p.y-a.y)*ab.y)/l2))
+		}
+		q := AddOrdered(a, ScaleOrdered(ab, t))
+		if d := distance(p, q); d < best {
+			best, nearest, direction = d, q, ab
+		}
+	}
+	return nearest, direction
+}
+
+func (w Waters) Contains(p OrderedPair) bool {
+	for _, body := range w {
+		if body.Contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Shore returns the shore of the first body p is in, or else the nearest
+// shore of all of them.
+func (w Waters) Shore(p OrderedPair) (point, normal OrderedPair) {
+	best := math.Inf(1)
+	for _, body := range w {
+		q, n := body.Shore(p)
+		if body.Contains(p) {
+			return q, n
+		}
+		if d := distance(p, q); d < best {
+			best, point, normal = d, q, n
+		}
+	}
+	return point, normal
+}
+
+// hasDryLand reports whether any point of a grid over a world of the given
+// width lies outside the water.
+func hasDryLand(water WaterBody, width float64) bool {
+	step := width / dryLandSamples
+	for i := 0; i < dryLandSamples; i++ {
+		for j := 0; j < dryLandSamples; j++ {
+			if !water.Contains(OrderedPair{x: (float64(i) + 0.5) * step, y: (float64(j) + 0.5) * step}) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ShoreDistance returns how far p is from the nearest water: 0 in the water
+// and +Inf when there is none.
+func ShoreDistance(p OrderedPair, water WaterBody) float64 {
+	if water.Contains(p) {
+		return 0
+	}
+	if w, ok := water.(Waters); ok && len(w) == 0 {
+		return math.Inf(1)
+	}
+	q, _ := water.Shore(p)
+	return distance(p, q)
+}
+
+// ShoreOffset returns the displacement from p to the nearest shore and its
+// length, as ShoreDistance, in a world of the given width and boundary. On a
+// torus the nearest shore may lie across an edge of the world, so it is
+// looked for from every image of p (see torusImages); water is the bodies
+// without their copies (see bodiesOfWater).
+func ShoreOffset(p OrderedPair, water WaterBody, width float64, boundary string) (offset OrderedPair, dist float64) {
+	images := []OrderedPair{p}
+	if (boundary == BoundaryTorus || boundary == "") && width > 0 {
+		images = torusImages(p, width, width)
+	}
+	dist = math.Inf(1)
+	for _, q := range images {
+		d := ShoreDistance(q, water)
+		if d == 0 {
+			return OrderedPair{}, 0
+		}
+		if d < dist {
+			shore, _ := water.Shore(q)
+			offset, dist = SubOrdered(shore, q), d
+		}
+	}
+	return offset, dist
+}
+
+// PushOutOfWater moves a position in the water to just outside the nearest
+// shore. Pushed out of one body, a position can land in another that overlaps
+// it; then it keeps going the same way, in ever longer steps, until it is on
+// dry land and bisects back to within twice shoreBuffer of where the water
+// ends.
+func PushOutOfWater(p OrderedPair, water WaterBody) OrderedPair {
+	if !water.Contains(p) {
+		return p
+	}
+	q, n := water.Shore(p)
+	wet, dry := q, AddOrdered(q, ScaleOrdered(n, shoreBuffer))
+	for step := shoreBuffer; water.Contains(dry); step *= 2 {
+		if step > 1e6 {
+			return dry
+		}
+		wet, dry = dry, AddOrdered(dry, ScaleOrdered(n, step))
+	}
+	for distance(wet, dry) > 2*shoreBuffer {
+		mid := ScaleOrdered(AddOrdered(wet, dry), 0.5)
+		if water.Contains(mid) {
+			wet = mid
+		} else {
+			dry = mid
+		}
+	}
+	return dry
+}
+
+// BounceOffShore reflects a velocity that points into the water at a shore
+// with the given outward normal; a velocity along or away from the shore is
+// returned unchanged.
+func BounceOffShore(v, normal OrderedPair) OrderedPair {
+	into := v.x*normal.x + v.y*normal.y
+	if into >= 0 {
+		return v
+	}
+	return SubOrdered(v, ScaleOrdered(normal, 2*into))
+}
@@ -2,7 +2,10 @@ module programingProject_main
 
 go 1.25.0
 
-require github.com/llgcode/draw2d v0.0.0-20240627062922-0ed1ff131195
+require (
+	github.com/llgcode/draw2d v0.0.0-20240627062922-0ed1ff131195
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect